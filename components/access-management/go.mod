@@ -7,6 +7,7 @@ require (
 	github.com/gorilla/mux v1.7.2
 	github.com/gregjones/httpcache v0.0.0-20190212212710-3befbb6ad0cc // indirect
 	github.com/imdario/mergo v0.3.7 // indirect
+	github.com/kubeflow/kubeflow/components/common v0.0.0-20200908101143-7f5e242f4671
 	github.com/kubeflow/kubeflow/components/profile-controller v0.0.0-20191008230951-321c1d3313b6
 	github.com/peterbourgon/diskv v2.0.1+incompatible // indirect
 	github.com/prometheus/client_golang v0.9.2
@@ -28,3 +29,7 @@ replace (
 	k8s.io/apimachinery => k8s.io/apimachinery v0.0.0-20190221084156-01f179d85dbc
 	k8s.io/client-go => k8s.io/client-go v0.0.0-20190528110200-4f3abb12cae2
 )
+
+// Build with the latest `common` module, which holds the role catalog shared
+// by the profile controller and kfam.
+replace github.com/kubeflow/kubeflow/components/common => ../common
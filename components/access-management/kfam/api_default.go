@@ -18,11 +18,11 @@ import (
 	"strconv"
 	"time"
 
-	profileRegister "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1beta1"
-	profilev1beta1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1beta1"
+	profileRegister "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1"
 	log "github.com/sirupsen/logrus"
-	istioRegister "istio.io/client-go/pkg/apis/security/v1beta1"
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/informers"
@@ -50,12 +50,13 @@ type KfamV1Alpha1Client struct {
 	userIdPrefix  string
 }
 
-func NewKfamClient(userIdHeader string, userIdPrefix string, clusterAdmin string) (*KfamV1Alpha1Client, error) {
+func NewKfamClient(userIdHeader string, userIdPrefix string, clusterAdmin string, roles *RoleCatalog) (*KfamV1Alpha1Client, error) {
 	profileRESTClient, err := getRESTClient(profileRegister.GroupName, profileRegister.GroupVersion)
 	if err != nil {
 		return nil, err
 	}
-	istioRESTClient, err := getRESTClient(istioRegister.GroupName, "v1beta1")
+	istioRESTClient, err := getRESTClient(istioSecurityClient.SchemeGroupVersion.Group,
+		istioSecurityClient.SchemeGroupVersion.Version)
 	if err != nil {
 		return nil, err
 	}
@@ -79,9 +80,11 @@ func NewKfamClient(userIdHeader string, userIdPrefix string, clusterAdmin string
 			restClient: profileRESTClient,
 		},
 		bindingClient: &BindingClient{
-			restClient:        istioRESTClient,
+			restClient:        profileRESTClient,
+			istioClient:       istioRESTClient,
 			kubeClient:        kubeClient,
 			roleBindingLister: roleBindingLister,
+			roles:             roles,
 		},
 		clusterAdmin: []string{clusterAdmin},
 		userIdHeader: userIdHeader,
@@ -134,15 +137,15 @@ func (c *KfamV1Alpha1Client) CreateBinding(w http.ResponseWriter, r *http.Reques
 func (c *KfamV1Alpha1Client) CreateProfile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	const action = "create"
-	var profile profilev1beta1.Profile
-	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+	profile := &unstructured.Unstructured{}
+	if err := json.NewDecoder(r.Body).Decode(&profile.Object); err != nil {
 		IncRequestErrorCounter("decode error", "", action, r.URL.Path,
 			SEVERITY_MAJOR)
 		writeResponse(w, []byte(err.Error()))
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
-	_, err := c.profileClient.Create(&profile)
+	err := c.profileClient.Create(profile)
 	if err != nil {
 		IncRequestErrorCounter(err.Error(), "", action, r.URL.Path,
 			SEVERITY_MAJOR)
@@ -225,9 +228,10 @@ func (c *KfamV1Alpha1Client) ReadBinding(w http.ResponseWriter, r *http.Request)
 		if err != nil {
 			w.WriteHeader(http.StatusForbidden)
 			writeResponse(w, []byte(err.Error()))
+			return
 		}
 		for _, profile := range profList.Items {
-			namespaces = append(namespaces, profile.Name)
+			namespaces = append(namespaces, profile.GetName())
 		}
 	} else {
 		namespaces = append(namespaces, queries.Get("namespace"))
@@ -299,12 +303,12 @@ func (c *KfamV1Alpha1Client) isClusterAdmin(queryUser string) bool {
 	return false
 }
 
-//isOwnerOrAdmin return true if queryUser is cluster admin or profile owner
+//isOwnerOrAdmin return true if queryUser is cluster admin or one of the profile owners
 func (c *KfamV1Alpha1Client) isOwnerOrAdmin(queryUser string, profileName string) bool {
 	isAdmin := c.isClusterAdmin(queryUser)
-	prof, err := c.profileClient.Get(profileName, metav1.GetOptions{})
+	owners, err := c.profileClient.GetOwners(profileName)
 	if err != nil {
 		return false
 	}
-	return isAdmin || (prof.Spec.Owner.Name == queryUser)
+	return isAdmin || isOwner(owners, queryUser)
 }
@@ -15,46 +15,43 @@
 package kfam
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
 
-	istioSecurity "istio.io/api/security/v1beta1"
-	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1 "k8s.io/client-go/listers/rbac/v1"
 	"k8s.io/client-go/rest"
 )
 
-const AuthorizationPolicy = "authorizationpolicies"
 const USER = "user"
 const ROLE = "role"
 
-//roleBindingNameMap maps frontend role names to k8s role names and vice-versa
-var roleBindingNameMap = map[string]string{
-	"kubeflow-admin": "admin",
-	"kubeflow-edit":  "edit",
-	"kubeflow-view":  "view",
-	"admin":          "kubeflow-admin",
-	"edit":           "kubeflow-edit",
-	"view":           "kubeflow-view",
-}
-
 type BindingInterface interface {
 	Create(binding *Binding, userIdHeader string, userIdPrefix string) error
 	Delete(binding *Binding) error
 	List(user string, namespaces []string, role string) (*BindingEntries, error)
 }
 
+// Resource of the Istio AuthorizationPolicies
+const AuthorizationPolicies = "authorizationpolicies"
+
 type BindingClient struct {
-	restClient        rest.Interface
-	kubeClient        *clientset.Clientset
+	restClient rest.Interface
+	// Client of the Istio AuthorizationPolicies kfam created before contributors were stored in the Profile
+	istioClient       rest.Interface
+	kubeClient        clientset.Interface
 	roleBindingLister v1.RoleBindingLister
+	// Maps frontend role names to k8s role names and vice-versa
+	roles *RoleCatalog
 }
 
 //getBindingName returns bindingName, which is combination of user kind, username, RoleRef kind, RoleRef name.
@@ -76,104 +73,166 @@ func getBindingName(binding *Binding) (string, error) {
 	return reg.ReplaceAllString(nameRaw, "-"), nil
 }
 
-func getAuthorizationPolicy(binding *Binding, userIdHeader string, userIdPrefix string) istioSecurity.AuthorizationPolicy {
-	return istioSecurity.AuthorizationPolicy{
-		Rules: []*istioSecurity.Rule{
-			{
-				When: []*istioSecurity.Condition{
-					{
-						Key: fmt.Sprintf("request.headers[%v]", userIdHeader),
-						Values: []string{
-							userIdPrefix + binding.User.Name,
-						},
-					},
-				},
-			},
-		},
+// contributor mirrors an entry of a Profile's spec.contributors. The Profile
+// is read and patched as JSON, so that fields kfam doesn't know are kept.
+type contributor struct {
+	Subject rbacv1.Subject `json:"subject"`
+	Role    string         `json:"role"`
+}
+
+type profileContributors struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Spec struct {
+		Contributors []contributor `json:"contributors"`
+	} `json:"spec"`
+}
+
+// The number of times a contributors update is retried on conflict.
+const maxContributorsRetries = 5
+
+// getContributor returns the Profile contributor of a binding, whose role is a role of the catalog or its ClusterRole.
+func getContributor(binding *Binding, roles *RoleCatalog) (contributor, error) {
+	role, ok := roleName(roles, binding.RoleRef.Name)
+	if !ok {
+		return contributor{}, fmt.Errorf("unknown role %v", binding.RoleRef.Name)
+	}
+	return contributor{
+		Subject: rbacv1.Subject{Kind: binding.User.Kind, Name: binding.User.Name},
+		Role:    role,
+	}, nil
+}
+
+// updateContributors applies "update" to the contributors of profile
+// "profileName", retrying if the Profile was modified concurrently. The
+// profile controller creates the RoleBindings and AuthorizationPolicies.
+func (c *BindingClient) updateContributors(profileName string,
+	update func([]contributor) ([]contributor, error)) error {
+	for i := 1; ; i++ {
+		raw, err := c.restClient.
+			Get().
+			Resource(Profiles).
+			Name(profileName).
+			VersionedParams(&metav1.GetOptions{}, scheme.ParameterCodec).
+			Do().
+			Raw()
+		if err != nil {
+			return err
+		}
+		profile := profileContributors{}
+		if err = json.Unmarshal(raw, &profile); err != nil {
+			return err
+		}
+		contributors, err := update(profile.Spec.Contributors)
+		if err != nil {
+			return err
+		}
+		profile.Spec.Contributors = contributors
+		patch, err := json.Marshal(profile)
+		if err != nil {
+			return err
+		}
+		// The resourceVersion in the patch makes it fail on concurrent updates
+		err = c.restClient.
+			Patch(types.MergePatchType).
+			Resource(Profiles).
+			Name(profileName).
+			Body(patch).
+			Do().
+			Error()
+		if !apierrors.IsConflict(err) || i == maxContributorsRetries {
+			return err
+		}
 	}
 }
 
+// Create adds the binding's user to the contributors of the referred Profile.
 func (c *BindingClient) Create(binding *Binding, userIdHeader string, userIdPrefix string) error {
 	// TODO: permission check before go ahead
-	bindingName, err := getBindingName(binding)
+	newContributor, err := getContributor(binding, c.roles)
 	if err != nil {
 		return err
 	}
-	roleBinding := rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{USER: binding.User.Name, ROLE: binding.RoleRef.Name},
-			Name:        bindingName,
-		},
-		RoleRef: rbacv1.RoleRef{
-			APIGroup: binding.RoleRef.APIGroup,
-			Kind:     binding.RoleRef.Kind,
-			Name:     roleBindingNameMap[binding.RoleRef.Name],
-		},
-		Subjects: []rbacv1.Subject{
-			*binding.User,
-		},
-	}
-	_, err = c.kubeClient.RbacV1().RoleBindings(binding.ReferredNamespace).Create(&roleBinding)
-	if err != nil {
-		return err
-	}
-
-	// create istio authorization policy
-	istioAuth := &istioSecurityClient.AuthorizationPolicy{
-		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{USER: binding.User.Name, ROLE: binding.RoleRef.Name},
-			Name:        bindingName,
-			Namespace:   binding.ReferredNamespace,
-		},
-		Spec: getAuthorizationPolicy(binding, userIdHeader, userIdPrefix),
-	}
-
-	result := istioSecurityClient.AuthorizationPolicy{}
-	return c.restClient.
-		Post().
-		Namespace(binding.ReferredNamespace).
-		Resource(AuthorizationPolicy).
-		Body(istioAuth).
-		Do().
-		Into(&result)
+	return c.updateContributors(binding.ReferredNamespace, func(contributors []contributor) ([]contributor, error) {
+		for _, existing := range contributors {
+			if existing == newContributor {
+				return nil, fmt.Errorf("%v %v is already a contributor of %v with role %v",
+					newContributor.Subject.Kind, newContributor.Subject.Name, binding.ReferredNamespace, newContributor.Role)
+			}
+		}
+		return append(contributors, newContributor), nil
+	})
 }
 
+// Delete removes the binding's user from the contributors of the referred Profile.
 func (c *BindingClient) Delete(binding *Binding) error {
 	// TODO: permission check before go ahead
-	// First check existence
-	bindingName, err := getBindingName(binding)
+	oldContributor, err := getContributor(binding, c.roles)
 	if err != nil {
 		return err
 	}
-	_, err = c.roleBindingLister.RoleBindings(binding.ReferredNamespace).Get(bindingName)
-	if err != nil {
-		return err
+	notContributor := false
+	err = c.updateContributors(binding.ReferredNamespace, func(contributors []contributor) ([]contributor, error) {
+		result := []contributor{}
+		for _, existing := range contributors {
+			if existing != oldContributor {
+				result = append(result, existing)
+			}
+		}
+		if len(result) == len(contributors) {
+			notContributor = true
+			return nil, fmt.Errorf("%v %v is not a contributor of %v with role %v",
+				oldContributor.Subject.Kind, oldContributor.Subject.Name, binding.ReferredNamespace, oldContributor.Role)
+		}
+		return result, nil
+	})
+	if notContributor {
+		if deleted, legacyErr := c.deleteLegacyBinding(binding.ReferredNamespace, oldContributor); deleted || legacyErr != nil {
+			return legacyErr
+		}
 	}
-	result := istioSecurityClient.AuthorizationPolicy{}
-	err = c.restClient.
-		Get().
-		Namespace(binding.ReferredNamespace).
-		Resource(AuthorizationPolicy).
-		Name(bindingName).
-		VersionedParams(&metav1.GetOptions{}, scheme.ParameterCodec).
-		Do().
-		Into(&result)
+	return err
+}
+
+// deleteLegacyBinding deletes the RoleBinding and AuthorizationPolicy of
+// "oldContributor" which kfam created before contributors were stored in the
+// Profile. The profile controller doesn't manage them until the contributor
+// is listed in the Profile. It returns false if there is no such RoleBinding.
+func (c *BindingClient) deleteLegacyBinding(namespace string, oldContributor contributor) (bool, error) {
+	// The names of the legacy bindings are based on the role, not its ClusterRole
+	bindingName, err := getBindingName(&Binding{
+		User:    &oldContributor.Subject,
+		RoleRef: &rbacv1.RoleRef{Kind: "ClusterRole", Name: oldContributor.Role},
+	})
 	if err != nil {
-		return err
+		return false, err
 	}
-	// Delete if exists
-	err = c.kubeClient.RbacV1().RoleBindings(binding.ReferredNamespace).Delete(bindingName, &metav1.DeleteOptions{})
-	if err != nil {
-		return err
+	roleBinding, err := c.roleBindingLister.RoleBindings(namespace).Get(bindingName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if metav1.GetControllerOf(roleBinding) != nil || roleBinding.Annotations[USER] != oldContributor.Subject.Name {
+		return false, nil
 	}
-	return c.restClient.
+	err = c.kubeClient.RbacV1().RoleBindings(namespace).Delete(bindingName, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return true, err
+	}
+	err = c.istioClient.
 		Delete().
-		Namespace(binding.ReferredNamespace).
-		Resource(AuthorizationPolicy).
+		Namespace(namespace).
+		Resource(AuthorizationPolicies).
 		Name(bindingName).
 		Body(&metav1.DeleteOptions{}).
 		Do().
 		Error()
+	if err != nil && !apierrors.IsNotFound(err) {
+		return true, err
+	}
+	return true, nil
 }
 
 func (c *BindingClient) List(user string, namespaces []string, role string) (*BindingEntries, error) {
@@ -202,6 +261,7 @@ func (c *BindingClient) List(user string, namespaces []string, role string) (*Bi
 				return nil, fmt.Errorf("binding subject length not equal to 1, actual length: %v",
 					len(roleBinding.Subjects))
 			}
+			roleName, _ := c.roles.RoleOf(roleBinding.RoleRef.Name)
 			binding := Binding{
 				User: &rbacv1.Subject{
 					Kind: roleBinding.Subjects[0].Kind,
@@ -210,7 +270,7 @@ func (c *BindingClient) List(user string, namespaces []string, role string) (*Bi
 				ReferredNamespace: ns,
 				RoleRef: &rbacv1.RoleRef{
 					Kind: roleBinding.RoleRef.Kind,
-					Name: roleBindingNameMap[roleBinding.RoleRef.Name],
+					Name: roleName,
 				},
 			}
 			bindings = append(bindings, binding)
@@ -1,9 +1,19 @@
 package kfam
 
 import (
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	listers "k8s.io/client-go/listers/rbac/v1"
+	restfake "k8s.io/client-go/rest/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 // Building Binding Object from k8s.io/api/rbac/v1
@@ -59,3 +69,116 @@ func TestGetBindingName(t *testing.T) {
 	}
 
 }
+
+func TestGetContributor(t *testing.T) {
+	var tests = []struct {
+		name     string
+		role     string
+		out      string
+		hasError bool
+	}{
+		{"short role", "edit", "edit", false},
+		{"kubeflow role", "kubeflow-view", "view", false},
+		{"unknown role", "owner", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binding := getBindingObject("lalith.vaka@zq.msds.kp.org")
+			binding.RoleRef.Name = tt.role
+			c, errorReturned := getContributor(binding, DefaultRoleCatalog())
+			if tt.hasError {
+				if errorReturned == nil {
+					t.Fatalf("Expected error but got none:  input: %q", tt.role)
+				}
+				return
+			}
+			if errorReturned != nil {
+				t.Fatalf("unExpected occured:  input: %q, errorReturned: %q", tt.role, errorReturned)
+			}
+			if c.Role != tt.out || c.Subject.Name != binding.User.Name || c.Subject.Kind != rbacv1.UserKind {
+				t.Fatalf("Value different than expected: input: %q, output: %v", tt.role, c)
+			}
+		})
+	}
+}
+
+func TestGetContributorCustomRole(t *testing.T) {
+	roles := DefaultRoleCatalog()
+	roles.Roles = append(roles.Roles, Role{Name: "pipeline-runner", ClusterRole: "kubeflow-pipeline-runner"})
+	for _, role := range []string{"pipeline-runner", "kubeflow-pipeline-runner"} {
+		binding := getBindingObject("lalith.vaka@zq.msds.kp.org")
+		binding.RoleRef.Name = role
+		c, err := getContributor(binding, roles)
+		if err != nil || c.Role != "pipeline-runner" {
+			t.Fatalf("Value different than expected: input: %q, output: %v, error: %v", role, c, err)
+		}
+	}
+	if name, _ := roles.RoleOf("kubeflow-pipeline-runner"); name != "pipeline-runner" {
+		t.Fatalf("Value different than expected: input: %q, output: %q", "kubeflow-pipeline-runner", name)
+	}
+}
+
+func TestDeleteLegacyBinding(t *testing.T) {
+	if err := istioSecurityClient.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	isController := true
+	legacy := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{
+		Name:        "user-bob-example-com-clusterrole-edit",
+		Namespace:   "alice",
+		Annotations: map[string]string{USER: "bob@example.com", ROLE: "edit"},
+	}}
+	managed := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{
+		Name:            "user-carol-example-com-clusterrole-edit",
+		Namespace:       "alice",
+		Annotations:     map[string]string{USER: "carol@example.com", ROLE: "edit"},
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Profile", Name: "alice", Controller: &isController}},
+	}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(legacy)
+	indexer.Add(managed)
+	deletedPolicies := []string{}
+	c := &BindingClient{
+		kubeClient:        fake.NewSimpleClientset(legacy, managed),
+		roleBindingLister: listers.NewRoleBindingLister(indexer),
+		istioClient: &restfake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs,
+			GroupVersion:         istioSecurityClient.SchemeGroupVersion,
+			Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				deletedPolicies = append(deletedPolicies, req.Method+" "+req.URL.Path)
+				return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+			}),
+		},
+	}
+
+	var tests = []struct {
+		name    string
+		user    string
+		deleted bool
+	}{
+		{"legacy binding", "bob@example.com", true},
+		{"binding managed by the profile", "carol@example.com", false},
+		{"no binding", "dave@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deletedPolicies = []string{}
+			old := contributor{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: tt.user}, Role: "edit"}
+			deleted, err := c.deleteLegacyBinding("alice", old)
+			if err != nil || deleted != tt.deleted {
+				t.Fatalf("Value different than expected: input: %q, output: %v, error: %v", tt.user, deleted, err)
+			}
+			if tt.deleted && (len(deletedPolicies) != 1 || !strings.HasPrefix(deletedPolicies[0], "DELETE ")) {
+				t.Fatalf("Expected the AuthorizationPolicy to be deleted, requests: %v", deletedPolicies)
+			}
+			if !tt.deleted && len(deletedPolicies) != 0 {
+				t.Fatalf("Expected no request, requests: %v", deletedPolicies)
+			}
+		})
+	}
+	roleBindings, _ := c.kubeClient.RbacV1().RoleBindings("alice").List(metav1.ListOptions{})
+	if len(roleBindings.Items) != 1 || roleBindings.Items[0].Name != managed.Name {
+		t.Fatalf("Expected only %v to be left, output: %v", managed.Name, roleBindings.Items)
+	}
+}
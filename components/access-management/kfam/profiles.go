@@ -15,18 +15,23 @@
 package kfam
 
 import (
-	"github.com/kubeflow/kubeflow/components/profile-controller/api/v1beta1"
+	"encoding/json"
+
+	profileRegister "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 )
 
+// ProfileInterface reads and writes v1 Profiles. They're handled as JSON, so
+// that the fields kfam doesn't know are kept.
 type ProfileInterface interface {
-	Create(profile *v1beta1.Profile) (*v1beta1.Profile, error)
+	Create(profile *unstructured.Unstructured) error
 	Delete(name string, opts *metav1.DeleteOptions) error
-	Get(name string, opts metav1.GetOptions) (*v1beta1.Profile, error)
-	List(opts metav1.ListOptions) (*v1beta1.ProfileList, error)
-	Update(profile *v1beta1.Profile) (*v1beta1.Profile, error)
+	GetOwners(name string) ([]rbacv1.Subject, error)
+	List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error)
 }
 
 type ProfileClient struct {
@@ -35,16 +40,21 @@ type ProfileClient struct {
 
 const Profiles = "profiles"
 
-func (c *ProfileClient) Create(profile *v1beta1.Profile) (*v1beta1.Profile, error) {
-	result := v1beta1.Profile{}
-	err := c.restClient.
+// Create creates "profile" as a v1 Profile. The fields of v1beta1 are a
+// subset of the v1 ones, so v1beta1 Profiles are created as is.
+func (c *ProfileClient) Create(profile *unstructured.Unstructured) error {
+	profile.SetAPIVersion(profileRegister.SchemeGroupVersion.String())
+	profile.SetKind("Profile")
+	body, err := profile.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return c.restClient.
 		Post().
 		Resource(Profiles).
-		Body(profile).
+		Body(body).
 		Do().
-		Into(&result)
-
-	return &result, err
+		Error()
 }
 
 func (c *ProfileClient) Delete(name string, opts *metav1.DeleteOptions) error {
@@ -57,39 +67,60 @@ func (c *ProfileClient) Delete(name string, opts *metav1.DeleteOptions) error {
 		Error()
 }
 
-func (c *ProfileClient) Get(name string, opts metav1.GetOptions) (*v1beta1.Profile, error) {
-	result := v1beta1.Profile{}
-	err := c.restClient.
+// profileOwners mirrors the owner fields of a Profile.
+type profileOwners struct {
+	Spec struct {
+		Owner  rbacv1.Subject   `json:"owner"`
+		Owners []rbacv1.Subject `json:"owners"`
+	} `json:"spec"`
+}
+
+// GetOwners returns the owners of the Profile "name": the deprecated owner
+// first, then the ones listed in owners.
+func (c *ProfileClient) GetOwners(name string) ([]rbacv1.Subject, error) {
+	raw, err := c.restClient.
 		Get().
 		Resource(Profiles).
 		Name(name).
-		VersionedParams(&opts, scheme.ParameterCodec).
+		VersionedParams(&metav1.GetOptions{}, scheme.ParameterCodec).
 		Do().
-		Into(&result)
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	profile := profileOwners{}
+	if err = json.Unmarshal(raw, &profile); err != nil {
+		return nil, err
+	}
+	owners := profile.Spec.Owners
+	if profile.Spec.Owner.Name != "" {
+		owners = append([]rbacv1.Subject{profile.Spec.Owner}, owners...)
+	}
+	return owners, nil
+}
 
-	return &result, err
+// isOwner returns whether "user" is one of "owners". Group owners aren't
+// matched, kfam only knows the user of a request.
+func isOwner(owners []rbacv1.Subject, user string) bool {
+	for _, owner := range owners {
+		if owner.Kind != rbacv1.GroupKind && owner.Name == user {
+			return true
+		}
+	}
+	return false
 }
 
-func (c *ProfileClient) List(opts metav1.ListOptions) (*v1beta1.ProfileList, error) {
-	result := v1beta1.ProfileList{}
-	err := c.restClient.
+func (c *ProfileClient) List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	raw, err := c.restClient.
 		Get().
 		Resource(Profiles).
 		VersionedParams(&opts, scheme.ParameterCodec).
 		Do().
-		Into(&result)
-
-	return &result, err
-}
-
-func (c *ProfileClient) Update(profile *v1beta1.Profile) (*v1beta1.Profile, error) {
-	result := v1beta1.Profile{}
-	err := c.restClient.
-		Put().
-		Resource(Profiles).
-		Body(profile).
-		Do().
-		Into(&result)
-
-	return &result, err
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	result := &unstructured.UnstructuredList{}
+	err = result.UnmarshalJSON(raw)
+	return result, err
 }
@@ -0,0 +1,31 @@
+package kfam
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestIsOwner(t *testing.T) {
+	owners := []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+		{Kind: rbacv1.UserKind, Name: "bob@example.com"},
+		{Kind: rbacv1.GroupKind, Name: "team-a"},
+	}
+	var tests = []struct {
+		user string
+		out  bool
+	}{
+		{"alice@example.com", true},
+		{"bob@example.com", true},
+		{"carol@example.com", false},
+		{"team-a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			if out := isOwner(owners, tt.user); out != tt.out {
+				t.Errorf("got %v, want %v", out, tt.out)
+			}
+		})
+	}
+}
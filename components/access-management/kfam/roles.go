@@ -0,0 +1,48 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kfam
+
+import (
+	"github.com/kubeflow/kubeflow/components/common/roles"
+)
+
+// Role maps the name of a role of the Profile contributors to the
+// ClusterRole of their RoleBindings.
+type Role = roles.Role
+
+// RoleCatalog is the list of the roles of the profiles, shared with the
+// profile controller.
+type RoleCatalog = roles.Catalog
+
+// DefaultRoleCatalog returns the roles used when no catalog is given.
+func DefaultRoleCatalog() *RoleCatalog {
+	return roles.DefaultCatalog()
+}
+
+// LoadRoleCatalog reads and validates the YAML or JSON catalog at "path",
+// as the profile controller does, or returns the default catalog if "path"
+// is empty.
+func LoadRoleCatalog(path string) (*RoleCatalog, error) {
+	return roles.LoadCatalog(path)
+}
+
+// roleName returns the role of "c" named "name", or else the one whose
+// ClusterRole is "name".
+func roleName(c *RoleCatalog, name string) (string, bool) {
+	if _, ok := c.ClusterRole(name); ok {
+		return name, true
+	}
+	return c.RoleOf(name)
+}
@@ -0,0 +1,42 @@
+package kfam
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRoleCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "roles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		name    string
+		catalog string
+		err     string
+	}{
+		{"valid", `{"roles": [{"name": "admin", "clusterRole": "kubeflow-admin"},
+			{"name": "edit", "clusterRole": "kubeflow-edit"}, {"name": "view", "clusterRole": "kubeflow-view"},
+			{"name": "pipeline-runner", "clusterRole": "kubeflow-pipeline-runner"}]}`, ""},
+		{"missing role", `{"roles": [{"name": "admin", "clusterRole": "kubeflow-admin"}]}`, "role edit is required"},
+		{"shared ClusterRole", `{"roles": [{"name": "admin", "clusterRole": "kubeflow-admin"},
+			{"name": "edit", "clusterRole": "kubeflow-edit"}, {"name": "view", "clusterRole": "kubeflow-edit"}]}`,
+			"used by several roles"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(path, []byte(tt.catalog), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadRoleCatalog(path)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Value different than expected: got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
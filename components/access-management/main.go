@@ -18,7 +18,7 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 
 	"github.com/kubeflow/kubeflow/components/access-management/kfam"
-	profile "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1beta1"
+	profile "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1"
 
 	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
 )
@@ -33,20 +33,31 @@ const USERIDPREFIX = "userid-prefix"
 // set cluster admin user id here.
 const CLUSTERADMIN = "cluster-admin"
 
+// set the path of the role catalog shared with the profile controller here.
+const ROLECATALOG = "role-catalog"
+
 func main() {
 	log.Printf("Server started")
 	var userIdHeader string
 	var userIdPrefix string
 	var clusterAdmin string
+	var roleCatalog string
 	flag.StringVar(&userIdHeader, USERIDHEADER, "x-goog-authenticated-user-email", "Key of request header containing user id")
 	flag.StringVar(&userIdPrefix, USERIDPREFIX, "accounts.google.com:", "Request header user id common prefix")
 	flag.StringVar(&clusterAdmin, CLUSTERADMIN, "", "cluster admin")
+	flag.StringVar(&roleCatalog, ROLECATALOG, "", "Path of the YAML file mapping the roles of contributors to ClusterRoles, kubeflow-admin, kubeflow-edit and kubeflow-view if empty")
 	flag.Parse()
 
 	profile.AddToScheme(scheme.Scheme)
 	istioSecurityClient.AddToScheme(scheme.Scheme)
 
-	profileClient, err := kfam.NewKfamClient(userIdHeader, userIdPrefix, clusterAdmin)
+	roles, err := kfam.LoadRoleCatalog(roleCatalog)
+	if err != nil {
+		log.Print(err)
+		panic(err)
+	}
+
+	profileClient, err := kfam.NewKfamClient(userIdHeader, userIdPrefix, clusterAdmin, roles)
 	if err != nil {
 		log.Print(err)
 		panic(err)
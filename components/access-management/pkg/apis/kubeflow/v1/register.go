@@ -1,5 +1,5 @@
 /*
-Copyright 2019 The Kubeflow Authors.
+Copyright 2021 The Kubeflow Authors.
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -14,25 +14,20 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// NOTE: Boilerplate only.  Ignore this file.
-
-// Package v1alpha1 contains API Schema definitions for the kubeflow v1alpha1 API group
-// +k8s:openapi-gen=true
-// +k8s:deepcopy-gen=package,register
-// +k8s:conversion-gen=github.com/kubeflow/kubeflow/components/profile-controller/pkg/apis/kubeflow
-// +k8s:defaulter-gen=TypeMeta
+// Package v1 registers the kubeflow.org/v1 API group version, in which kfam
+// reads and writes Profiles. Profiles are handled as JSON, so that the fields
+// kfam doesn't know are kept, and only the common API types are registered.
 // +groupName=kubeflow.org
-package v1beta1
+package v1
 
 import (
-	profilev1beta1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 const GroupName = "kubeflow.org"
-const GroupVersion = "v1beta1"
+const GroupVersion = "v1"
 
 var (
 	// SchemeGroupVersion is group version used to register these objects
@@ -46,10 +41,6 @@ var (
 )
 
 func addKnownTypes(scheme *runtime.Scheme) error {
-	scheme.AddKnownTypes(SchemeGroupVersion,
-		&profilev1beta1.Profile{},
-		&profilev1beta1.ProfileList{},
-	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
 }
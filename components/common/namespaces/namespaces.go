@@ -0,0 +1,81 @@
+package namespaces
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Parse returns the namespaces of a comma separated list, ignoring empty
+// entries.
+func Parse(list string) []string {
+	namespaces := []string{}
+	for _, ns := range strings.Split(list, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// Resolve returns the sorted namespaces a controller should watch: the ones
+// in the comma separated list and the ones whose labels match selector.
+// It returns an empty slice, meaning the whole cluster, only if both list
+// and selector are empty. Namespaces matching the selector are resolved
+// once, so namespaces labeled later are picked up after a restart.
+func Resolve(cfg *rest.Config, list, selector string) ([]string, error) {
+	namespaces := Parse(list)
+	if selector != "" {
+		if _, err := labels.Parse(selector); err != nil {
+			return nil, fmt.Errorf("invalid namespace selector %q: %v", selector, err)
+		}
+		clientset, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		nsList, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("error listing namespaces matching %q: %v", selector, err)
+		}
+		if len(nsList.Items) == 0 {
+			return nil, fmt.Errorf("no namespace matches selector %q", selector)
+		}
+		for _, ns := range nsList.Items {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	return dedupe(namespaces), nil
+}
+
+// SetManagerOptions restricts the cache of the manager, and therefore every
+// watch of its controllers, to namespaces. Empty namespaces leaves the
+// manager watching the whole cluster.
+func SetManagerOptions(opts *manager.Options, namespaces []string) {
+	switch len(namespaces) {
+	case 0:
+	case 1:
+		opts.Namespace = namespaces[0]
+	default:
+		opts.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+}
+
+func dedupe(namespaces []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, ns := range namespaces {
+		if !seen[ns] {
+			seen[ns] = true
+			result = append(result, ns)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -120,7 +120,7 @@ func CopyStatefulSetFields(from, to *appsv1.StatefulSet) bool {
 	}
 	to.Annotations = from.Annotations
 
-	if from.Spec.Replicas != to.Spec.Replicas {
+	if !equalReplicas(from.Spec.Replicas, to.Spec.Replicas) {
 		to.Spec.Replicas = from.Spec.Replicas
 		requireUpdate = true
 	}
@@ -149,7 +149,7 @@ func CopyDeploymentSetFields(from, to *appsv1.Deployment) bool {
 	}
 	to.Annotations = from.Annotations
 
-	if from.Spec.Replicas != to.Spec.Replicas {
+	if !equalReplicas(from.Spec.Replicas, to.Spec.Replicas) {
 		to.Spec.Replicas = from.Spec.Replicas
 		requireUpdate = true
 	}
@@ -162,6 +162,14 @@ func CopyDeploymentSetFields(from, to *appsv1.Deployment) bool {
 	return requireUpdate
 }
 
+// equalReplicas compares the values of the replicas of two specs.
+func equalReplicas(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 // CopyServiceFields copies the owned fields from one Service to another
 func CopyServiceFields(from, to *corev1.Service) bool {
 	requireUpdate := false
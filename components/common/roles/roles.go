@@ -0,0 +1,139 @@
+package roles
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Roles the profile controller itself binds: owners are admins,
+// "default-editor" and "default-viewer" are editors and viewers.
+const (
+	ADMIN = "admin"
+	EDIT  = "edit"
+	VIEW  = "view"
+)
+
+// Role maps the name of a role, used in the contributors of the profiles and
+// by kfam, to the ClusterRole its RoleBindings refer to.
+type Role struct {
+	Name        string `json:"name"`
+	ClusterRole string `json:"clusterRole"`
+}
+
+// Catalog is the list of the roles of the profiles, usually mounted from a
+// ConfigMap shared by the profile controller and kfam.
+type Catalog struct {
+	Roles []Role `json:"roles"`
+}
+
+// DefaultCatalog returns the roles used when no catalog is given.
+func DefaultCatalog() *Catalog {
+	return &Catalog{Roles: []Role{
+		{Name: ADMIN, ClusterRole: "kubeflow-admin"},
+		{Name: EDIT, ClusterRole: "kubeflow-edit"},
+		{Name: VIEW, ClusterRole: "kubeflow-view"},
+	}}
+}
+
+// LoadCatalog reads and validates the YAML or JSON catalog at "path", or
+// returns the default catalog if "path" is empty.
+func LoadCatalog(path string) (*Catalog, error) {
+	if path == "" {
+		return DefaultCatalog(), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseCatalog(f)
+}
+
+// ParseCatalog reads and validates a YAML or JSON catalog.
+func ParseCatalog(r io.Reader) (*Catalog, error) {
+	catalog := &Catalog{}
+	if err := yaml.NewYAMLOrJSONDecoder(r, 4096).Decode(catalog); err != nil {
+		return nil, fmt.Errorf("invalid role catalog: %v", err)
+	}
+	if err := catalog.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid role catalog: %v", err)
+	}
+	return catalog, nil
+}
+
+// Validate checks that the roles have valid names and ClusterRoles, that
+// neither is declared twice, as kfam maps ClusterRoles back to roles, and
+// that the roles bound by the profile controller are declared.
+func (c *Catalog) Validate() error {
+	names := map[string]bool{}
+	clusterRoles := map[string]bool{}
+	for _, role := range c.Roles {
+		if errs := validation.IsDNS1123Label(role.Name); len(errs) > 0 {
+			return fmt.Errorf("role name %q is invalid: %v", role.Name, errs[0])
+		}
+		if msgs := path.ValidatePathSegmentName(role.ClusterRole, false); role.ClusterRole == "" || len(msgs) > 0 {
+			return fmt.Errorf("ClusterRole %q of role %v is invalid", role.ClusterRole, role.Name)
+		}
+		if names[role.Name] {
+			return fmt.Errorf("role %v is declared twice", role.Name)
+		}
+		if clusterRoles[role.ClusterRole] {
+			return fmt.Errorf("ClusterRole %v is used by several roles", role.ClusterRole)
+		}
+		names[role.Name] = true
+		clusterRoles[role.ClusterRole] = true
+	}
+	for _, name := range []string{ADMIN, EDIT, VIEW} {
+		if !names[name] {
+			return fmt.Errorf("role %v is required", name)
+		}
+	}
+	return nil
+}
+
+// ClusterRole returns the ClusterRole of role "name", and whether the role
+// exists.
+func (c *Catalog) ClusterRole(name string) (string, bool) {
+	for _, role := range c.Roles {
+		if role.Name == name {
+			return role.ClusterRole, true
+		}
+	}
+	return "", false
+}
+
+// RoleOf returns the role whose ClusterRole is "clusterRole", and whether
+// there is one.
+func (c *Catalog) RoleOf(clusterRole string) (string, bool) {
+	for _, role := range c.Roles {
+		if role.ClusterRole == clusterRole {
+			return role.Name, true
+		}
+	}
+	return "", false
+}
+
+// ClusterRoles returns the ClusterRoles of all the roles, sorted.
+func (c *Catalog) ClusterRoles() []string {
+	clusterRoles := []string{}
+	for _, role := range c.Roles {
+		clusterRoles = append(clusterRoles, role.ClusterRole)
+	}
+	sort.Strings(clusterRoles)
+	return clusterRoles
+}
+
+// Names returns the names of all the roles, in the order of the catalog.
+func (c *Catalog) Names() []string {
+	names := []string{}
+	for _, role := range c.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
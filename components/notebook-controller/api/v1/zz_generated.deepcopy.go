@@ -66,6 +66,22 @@ func (in *NotebookCondition) DeepCopy() *NotebookCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotebookLifecycleEvent) DeepCopyInto(out *NotebookLifecycleEvent) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotebookLifecycleEvent.
+func (in *NotebookLifecycleEvent) DeepCopy() *NotebookLifecycleEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(NotebookLifecycleEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NotebookList) DeepCopyInto(out *NotebookList) {
 	*out = *in
@@ -125,6 +141,13 @@ func (in *NotebookStatus) DeepCopyInto(out *NotebookStatus) {
 		}
 	}
 	in.ContainerState.DeepCopyInto(&out.ContainerState)
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]NotebookLifecycleEvent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotebookStatus.
@@ -27,6 +27,7 @@ func (src *Notebook) ConvertTo(dstRaw conversion.Hub) error {
 	dst.Spec.Template.Spec = src.Spec.Template.Spec
 	dst.Status.ReadyReplicas = src.Status.ReadyReplicas
 	dst.Status.ContainerState = src.Status.ContainerState
+	dst.Status.Ready = src.Status.Ready
 	conditions := []nbv1beta1.NotebookCondition{}
 	for _, c := range src.Status.Conditions {
 		newc := nbv1beta1.NotebookCondition{
@@ -38,6 +39,11 @@ func (src *Notebook) ConvertTo(dstRaw conversion.Hub) error {
 		conditions = append(conditions, newc)
 	}
 	dst.Status.Conditions = conditions
+	history := []nbv1beta1.NotebookLifecycleEvent{}
+	for _, e := range src.Status.History {
+		history = append(history, nbv1beta1.NotebookLifecycleEvent(e))
+	}
+	dst.Status.History = history
 
 	return nil
 }
@@ -53,6 +59,7 @@ func (dst *Notebook) ConvertFrom(srcRaw conversion.Hub) error {
 	dst.Spec.Template.Spec = src.Spec.Template.Spec
 	dst.Status.ReadyReplicas = src.Status.ReadyReplicas
 	dst.Status.ContainerState = src.Status.ContainerState
+	dst.Status.Ready = src.Status.Ready
 	conditions := []NotebookCondition{}
 	for _, c := range src.Status.Conditions {
 		newc := NotebookCondition{
@@ -64,6 +71,11 @@ func (dst *Notebook) ConvertFrom(srcRaw conversion.Hub) error {
 		conditions = append(conditions, newc)
 	}
 	dst.Status.Conditions = conditions
+	history := []NotebookLifecycleEvent{}
+	for _, e := range src.Status.History {
+		history = append(history, NotebookLifecycleEvent(e))
+	}
+	dst.Status.History = history
 
 	return nil
 }
@@ -42,6 +42,13 @@ type NotebookStatus struct {
 	ReadyReplicas int32 `json:"readyReplicas"`
 	// ContainerState is the state of underlying container.
 	ContainerState corev1.ContainerState `json:"containerState"`
+	// Ready is true when the notebook container passes its readiness probe.
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+	// History is a list of the most recent lifecycle actions taken on the
+	// Notebook, newest first.
+	// +optional
+	History []NotebookLifecycleEvent `json:"history,omitempty"`
 }
 
 type NotebookCondition struct {
@@ -58,6 +65,24 @@ type NotebookCondition struct {
 	Message string `json:"message,omitempty"`
 }
 
+type NotebookLifecycleEvent struct {
+	// Time at which the action was recorded.
+	Time metav1.Time `json:"time"`
+	// Action that was taken. Possible values are Create|Stop|Start|Update
+	Action string `json:"action"`
+	// Trigger is what caused the action. Possible values are Culler|UserAnnotation|SpecChange
+	Trigger string `json:"trigger"`
+	// State of the Notebook before the action.
+	// +optional
+	PreviousState string `json:"previousState,omitempty"`
+	// State of the Notebook after the action.
+	// +optional
+	NewState string `json:"newState,omitempty"`
+	// Human readable details about the action.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
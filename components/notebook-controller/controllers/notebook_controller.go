@@ -19,16 +19,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/go-logr/logr"
 	reconcilehelper "github.com/kubeflow/kubeflow/components/common/reconcilehelper"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/audit"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/metrics"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -36,6 +38,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -50,6 +53,14 @@ const DefaultServingPort = 80
 const AnnotationRewriteURI = "notebooks.kubeflow.org/http-rewrite-uri"
 const AnnotationHeadersRequestSet = "notebooks.kubeflow.org/http-headers-request-set"
 
+// AnnotationProxySidecar names the entry of the controller configuration's
+// proxySidecars to inject in the Notebook's Pod.
+const AnnotationProxySidecar = "notebooks.kubeflow.org/proxy-sidecar"
+
+// The name of the injected proxy container and of its port.
+const ProxySidecarContainerName = "notebook-proxy"
+const ProxySidecarPortName = "proxy-port"
+
 // The default fsGroup of PodSecurityContext.
 // https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.11/#podsecuritycontext-v1-core
 const DefaultFSGroup = int64(100)
@@ -73,6 +84,10 @@ type NotebookReconciler struct {
 	Scheme        *runtime.Scheme
 	Metrics       *metrics.Metrics
 	EventRecorder record.EventRecorder
+	Config        *config.Store
+	// AuditSink optionally receives a copy of every lifecycle event recorded
+	// in the Notebook's status history.
+	AuditSink *audit.Sink
 }
 
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
@@ -85,6 +100,7 @@ type NotebookReconciler struct {
 func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("notebook", req.NamespacedName)
+	cfg := r.Config.Get()
 
 	// TODO(yanniszark): Can we avoid reconciling Events and Notebook in the same queue?
 	event := &corev1.Event{}
@@ -115,15 +131,22 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
 
+	sidecar, err := proxySidecar(instance, cfg)
+	if err != nil {
+		log.Error(err, "unable to inject proxy sidecar")
+		r.EventRecorder.Event(instance, corev1.EventTypeWarning, "InvalidProxySidecar", err.Error())
+		return ctrl.Result{}, err
+	}
+
 	// Reconcile StatefulSet
-	ss := generateStatefulSet(instance)
+	ss := generateStatefulSet(instance, cfg, sidecar)
 	if err := ctrl.SetControllerReference(instance, ss, r.Scheme); err != nil {
 		return ctrl.Result{}, err
 	}
 	// Check if the StatefulSet already exists
 	foundStateful := &appsv1.StatefulSet{}
 	justCreated := false
-	err := r.Get(ctx, types.NamespacedName{Name: ss.Name, Namespace: ss.Namespace}, foundStateful)
+	err = r.Get(ctx, types.NamespacedName{Name: ss.Name, Namespace: ss.Namespace}, foundStateful)
 	if err != nil && apierrs.IsNotFound(err) {
 		log.Info("Creating StatefulSet", "namespace", ss.Namespace, "name", ss.Name)
 		r.Metrics.NotebookCreation.WithLabelValues(ss.Namespace).Inc()
@@ -134,11 +157,18 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			r.Metrics.NotebookFailCreation.WithLabelValues(ss.Namespace).Inc()
 			return ctrl.Result{}, err
 		}
+		event := audit.NewEvent(audit.ActionCreate, audit.TriggerSpecChange,
+			"", statefulSetState(ss), "StatefulSet created")
+		if err = r.recordLifecycleEvent(ctx, instance, event); err != nil {
+			return ctrl.Result{}, err
+		}
 	} else if err != nil {
 		log.Error(err, "error getting Statefulset")
 		return ctrl.Result{}, err
 	}
 	// Update the foundStateful object and write the result back if there are any changes
+	oldState := statefulSetState(foundStateful)
+	oldTemplate := foundStateful.Spec.Template.DeepCopy()
 	if !justCreated && reconcilehelper.CopyStatefulSetFields(ss, foundStateful) {
 		log.Info("Updating StatefulSet", "namespace", ss.Namespace, "name", ss.Name)
 		err = r.Update(ctx, foundStateful)
@@ -146,10 +176,18 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			log.Error(err, "unable to update Statefulset")
 			return ctrl.Result{}, err
 		}
+		// The generated template lacks the fields defaulted by the API server,
+		// compare the one it returned to find out whether it actually changed.
+		templateChanged := !apiequality.Semantic.DeepEqual(oldTemplate, &foundStateful.Spec.Template)
+		if event, ok := statefulSetUpdateEvent(instance, oldState, statefulSetState(ss), templateChanged); ok {
+			if err = r.recordLifecycleEvent(ctx, instance, event); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 	}
 
 	// Reconcile service
-	service := generateService(instance)
+	service := generateService(instance, sidecar)
 	if err := ctrl.SetControllerReference(instance, service, r.Scheme); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -180,8 +218,8 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	// Reconcile virtual service if we use ISTIO.
-	if os.Getenv("USE_ISTIO") == "true" {
-		err = r.reconcileVirtualService(instance)
+	if cfg.UseIstio {
+		err = r.reconcileVirtualService(instance, cfg)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -204,6 +242,12 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	if err != nil && apierrs.IsNotFound(err) {
 		// This should be reconciled by the StatefulSet
 		log.Info("Pod not found...")
+		if instance.Status.Ready {
+			instance.Status.Ready = false
+			if err = r.Status().Update(ctx, instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 	} else if err != nil {
 		return ctrl.Result{}, err
 	} else {
@@ -219,13 +263,15 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 				if pod.Status.ContainerStatuses[i].Name != instance.Name {
 					continue
 				}
-				if pod.Status.ContainerStatuses[i].State == instance.Status.ContainerState {
+				if pod.Status.ContainerStatuses[i].State == instance.Status.ContainerState &&
+					pod.Status.ContainerStatuses[i].Ready == instance.Status.Ready {
 					continue
 				}
 
 				log.Info("Updating Notebook CR state: ", "namespace", instance.Namespace, "name", instance.Name)
 				cs := pod.Status.ContainerStatuses[i].State
 				instance.Status.ContainerState = cs
+				instance.Status.Ready = pod.Status.ContainerStatuses[i].Ready
 				oldConditions := instance.Status.Conditions
 				newCondition := getNextCondition(cs)
 				// Append new condition
@@ -250,7 +296,7 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	// Check if the Notebook needs to be stopped
-	if podFound && culler.NotebookNeedsCulling(instance.ObjectMeta) {
+	if podFound && culler.NotebookNeedsCulling(instance.ObjectMeta, cfg) {
 		log.Info(fmt.Sprintf(
 			"Notebook %s/%s needs culling. Setting annotations",
 			instance.Namespace, instance.Name))
@@ -262,16 +308,79 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+		event := audit.NewEvent(audit.ActionStop, audit.TriggerCuller,
+			audit.StateRunning, audit.StateStopped, "Notebook was idle")
+		if err = r.recordLifecycleEvent(ctx, instance, event); err != nil {
+			return ctrl.Result{}, err
+		}
 	} else if podFound && !culler.StopAnnotationIsSet(instance.ObjectMeta) {
 		// The Pod is either too fresh, or the idle time has passed and it has
 		// received traffic. In this case we will be periodically checking if
 		// it needs culling.
-		return ctrl.Result{RequeueAfter: culler.GetRequeueTime()}, nil
+		return ctrl.Result{RequeueAfter: culler.GetRequeueTime(cfg)}, nil
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// recordLifecycleEvent adds the event to the Notebook's status history and
+// writes it to the audit sink, if one is configured.
+func (r *NotebookReconciler) recordLifecycleEvent(ctx context.Context, instance *v1beta1.Notebook,
+	event v1beta1.NotebookLifecycleEvent) error {
+	log := r.Log.WithValues("notebook", types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace})
+	log.Info("Recording lifecycle event", "action", event.Action, "trigger", event.Trigger,
+		"previousState", event.PreviousState, "newState", event.NewState)
+	if err := r.AuditSink.Write(instance.Namespace, instance.Name, event); err != nil {
+		// The status history is the source of truth, don't fail the
+		// reconciliation because of the sink.
+		log.Error(err, "unable to write lifecycle event to the audit sink")
+	}
+	// Retry on conflicts with a fresh copy of the Notebook, so that the event
+	// isn't lost: the StatefulSet already exists when the request is retried.
+	refetch := false
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if refetch {
+			if err := r.Get(ctx, types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, instance); err != nil {
+				return err
+			}
+		}
+		refetch = true
+		audit.AppendEvent(&instance.Status, event)
+		return r.Status().Update(ctx, instance)
+	})
+}
+
+func statefulSetState(ss *appsv1.StatefulSet) string {
+	if ss.Spec.Replicas != nil && *ss.Spec.Replicas == 0 {
+		return audit.StateStopped
+	}
+	return audit.StateRunning
+}
+
+// statefulSetUpdateEvent describes the lifecycle action performed by an update
+// of the Notebook's StatefulSet. It returns false if there is nothing to
+// record: neither the state nor the pod template changed, or the action has
+// already been recorded, as happens when the culler stops the Notebook.
+func statefulSetUpdateEvent(instance *v1beta1.Notebook, oldState, newState string,
+	templateChanged bool) (v1beta1.NotebookLifecycleEvent, bool) {
+	if oldState == newState {
+		if !templateChanged {
+			return v1beta1.NotebookLifecycleEvent{}, false
+		}
+		return audit.NewEvent(audit.ActionUpdate, audit.TriggerSpecChange,
+			oldState, newState, "StatefulSet updated"), true
+	}
+	if audit.LastState(instance.Status) == newState {
+		return v1beta1.NotebookLifecycleEvent{}, false
+	}
+	if newState == audit.StateStopped {
+		return audit.NewEvent(audit.ActionStop, audit.TriggerUserAnnotation,
+			oldState, newState, fmt.Sprintf("Annotation %s was set", culler.STOP_ANNOTATION)), true
+	}
+	return audit.NewEvent(audit.ActionStart, audit.TriggerUserAnnotation,
+		oldState, newState, fmt.Sprintf("Annotation %s was removed", culler.STOP_ANNOTATION)), true
+}
+
 func getNextCondition(cs corev1.ContainerState) v1beta1.NotebookCondition {
 	var nbtype = ""
 	var nbreason = ""
@@ -298,7 +407,36 @@ func getNextCondition(cs corev1.ContainerState) v1beta1.NotebookCondition {
 	return newCondition
 }
 
-func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
+// proxySidecar returns the proxy sidecar requested by the Notebook's
+// annotation, or nil if there is none. Its port must not be used by the
+// containers of the Notebook, which share the Pod's network namespace.
+func proxySidecar(instance *v1beta1.Notebook, cfg *config.Config) (*config.ProxySidecarConfig, error) {
+	name := instance.ObjectMeta.Annotations[AnnotationProxySidecar]
+	if name == "" {
+		return nil, nil
+	}
+	sidecar, ok := cfg.ProxySidecars[name]
+	if !ok {
+		return nil, fmt.Errorf("proxy sidecar %q requested by annotation %s is not configured",
+			name, AnnotationProxySidecar)
+	}
+	for i, container := range instance.Spec.Template.Spec.Containers {
+		ports := container.Ports
+		// The first container gets the default port, see generateStatefulSet
+		if i == 0 && ports == nil {
+			ports = []corev1.ContainerPort{{ContainerPort: DefaultContainerPort}}
+		}
+		for _, port := range ports {
+			if port.ContainerPort == sidecar.Port {
+				return nil, fmt.Errorf("port %d of proxy sidecar %q is already used by container %q",
+					sidecar.Port, name, container.Name)
+			}
+		}
+	}
+	return &sidecar, nil
+}
+
+func generateStatefulSet(instance *v1beta1.Notebook, cfg *config.Config, sidecar *config.ProxySidecarConfig) *appsv1.StatefulSet {
 	replicas := int32(1)
 	if culler.StopAnnotationIsSet(instance.ObjectMeta) {
 		replicas = 0
@@ -321,7 +459,9 @@ func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
 					"statefulset":   instance.Name,
 					"notebook-name": instance.Name,
 				}},
-				Spec: instance.Spec.Template.Spec,
+				// Copied, so that the defaults set below don't leak into the
+				// Notebook, which is updated when culled.
+				Spec: *instance.Spec.Template.Spec.DeepCopy(),
 			},
 		},
 	}
@@ -349,12 +489,16 @@ func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
 		Name:  "NB_PREFIX",
 		Value: "/notebook/" + instance.Namespace + "/" + instance.Name,
 	})
+	setDefaultProbes(container, instance, cfg, sidecar != nil)
+	if sidecar != nil {
+		injectProxySidecar(podSpec, instance, sidecar)
+	}
 
 	// For some platforms (like OpenShift), adding fsGroup: 100 is troublesome.
 	// This allows for those platforms to bypass the automatic addition of the fsGroup
 	// and will allow for the Pod Security Policy controller to make an appropriate choice
 	// https://github.com/kubernetes-sigs/controller-runtime/issues/4617
-	if value, exists := os.LookupEnv("ADD_FSGROUP"); !exists || value == "true" {
+	if cfg.AddFSGroup {
 		if podSpec.SecurityContext == nil {
 			fsGroup := DefaultFSGroup
 			podSpec.SecurityContext = &corev1.PodSecurityContext{
@@ -365,13 +509,92 @@ func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
 	return ss
 }
 
-func generateService(instance *v1beta1.Notebook) *corev1.Service {
+// setDefaultProbes sets the probes configured for the container's image,
+// unless the user specified their own. Behind a proxy sidecar the IDE is
+// served from the root, otherwise under NB_PREFIX.
+func setDefaultProbes(container *corev1.Container, instance *v1beta1.Notebook, cfg *config.Config, behindProxy bool) {
+	probeCfg := cfg.ProbeFor(container.Image)
+	if probeCfg == nil {
+		return
+	}
+	prefix := "/notebook/" + instance.Namespace + "/" + instance.Name
+	if behindProxy {
+		prefix = ""
+	}
+	newProbe := func(path string) *corev1.Probe {
+		return &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: prefix + path,
+					Port: intstr.FromInt(int(container.Ports[0].ContainerPort)),
+				},
+			},
+			InitialDelaySeconds: probeCfg.InitialDelaySeconds,
+			PeriodSeconds:       probeCfg.PeriodSeconds,
+			FailureThreshold:    probeCfg.FailureThreshold,
+		}
+	}
+	if container.ReadinessProbe == nil && probeCfg.ReadinessPath != "" {
+		container.ReadinessProbe = newProbe(probeCfg.ReadinessPath)
+	}
+	if container.LivenessProbe == nil && probeCfg.LivenessPath != "" {
+		container.LivenessProbe = newProbe(probeCfg.LivenessPath)
+	}
+}
+
+// injectProxySidecar adds the proxy container in front of the Notebook's
+// first container. The proxy serves NB_PREFIX and forwards to UPSTREAM_PORT.
+func injectProxySidecar(podSpec *corev1.PodSpec, instance *v1beta1.Notebook, sidecar *config.ProxySidecarConfig) {
+	for _, c := range podSpec.Containers {
+		if c.Name == ProxySidecarContainerName {
+			return
+		}
+	}
+	upstreamPort := podSpec.Containers[0].Ports[0].ContainerPort
+	proxy := corev1.Container{
+		Name:  ProxySidecarContainerName,
+		Image: sidecar.Image,
+		Args:  sidecar.Args,
+		Ports: []corev1.ContainerPort{
+			{
+				ContainerPort: sidecar.Port,
+				Name:          ProxySidecarPortName,
+				Protocol:      "TCP",
+			},
+		},
+		Env: []corev1.EnvVar{
+			{Name: "NB_PREFIX", Value: "/notebook/" + instance.Namespace + "/" + instance.Name},
+			{Name: "UPSTREAM_PORT", Value: fmt.Sprint(upstreamPort)},
+			{Name: "PROXY_PORT", Value: fmt.Sprint(sidecar.Port)},
+		},
+		Resources: sidecar.Resources,
+	}
+	if sidecar.HealthPath != "" {
+		probe := &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: sidecar.HealthPath,
+					Port: intstr.FromInt(int(sidecar.Port)),
+				},
+			},
+		}
+		proxy.ReadinessProbe = probe
+		proxy.LivenessProbe = probe.DeepCopy()
+	}
+	podSpec.Containers = append(podSpec.Containers, proxy)
+}
+
+func generateService(instance *v1beta1.Notebook, sidecar *config.ProxySidecarConfig) *corev1.Service {
 	// Define the desired Service object
 	port := DefaultContainerPort
 	containerPorts := instance.Spec.Template.Spec.Containers[0].Ports
 	if containerPorts != nil {
 		port = int(containerPorts[0].ContainerPort)
 	}
+	// The proxy sidecar, when there is one, is the entrypoint of the Notebook
+	if sidecar != nil {
+		port = int(sidecar.Port)
+	}
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      instance.Name,
@@ -398,10 +621,9 @@ func virtualServiceName(kfName string, namespace string) string {
 	return fmt.Sprintf("notebook-%s-%s", namespace, kfName)
 }
 
-func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructured, error) {
+func generateVirtualService(instance *v1beta1.Notebook, cfg *config.Config) (*unstructured.Unstructured, error) {
 	name := instance.Name
 	namespace := instance.Namespace
-	clusterDomain := "cluster.local"
 	prefix := fmt.Sprintf("/notebook/%s/%s/", namespace, name)
 
 	// unpack annotations from Notebook resource
@@ -416,10 +638,7 @@ func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructu
 		rewrite = annotations[AnnotationRewriteURI]
 	}
 
-	if clusterDomainFromEnv, ok := os.LookupEnv("CLUSTER_DOMAIN"); ok {
-		clusterDomain = clusterDomainFromEnv
-	}
-	service := fmt.Sprintf("%s.%s.svc.%s", name, namespace, clusterDomain)
+	service := fmt.Sprintf("%s.%s.svc.%s", name, namespace, cfg.ClusterDomain)
 
 	vsvc := &unstructured.Unstructured{}
 	vsvc.SetAPIVersion("networking.istio.io/v1alpha3")
@@ -430,11 +649,7 @@ func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructu
 		return nil, fmt.Errorf("Set .spec.hosts error: %v", err)
 	}
 
-	istioGateway := os.Getenv("ISTIO_GATEWAY")
-	if len(istioGateway) == 0 {
-		istioGateway = "kubeflow/kubeflow-gateway"
-	}
-	if err := unstructured.SetNestedStringSlice(vsvc.Object, []string{istioGateway},
+	if err := unstructured.SetNestedStringSlice(vsvc.Object, []string{cfg.IstioGateway},
 		"spec", "gateways"); err != nil {
 		return nil, fmt.Errorf("Set .spec.gateways error: %v", err)
 	}
@@ -495,9 +710,9 @@ func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructu
 
 }
 
-func (r *NotebookReconciler) reconcileVirtualService(instance *v1beta1.Notebook) error {
+func (r *NotebookReconciler) reconcileVirtualService(instance *v1beta1.Notebook, cfg *config.Config) error {
 	log := r.Log.WithValues("notebook", instance.Namespace)
-	virtualService, err := generateVirtualService(instance)
+	virtualService, err := generateVirtualService(instance, cfg)
 	if err := ctrl.SetControllerReference(instance, virtualService, r.Scheme); err != nil {
 		return err
 	}
@@ -576,7 +791,7 @@ func (r *NotebookReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&appsv1.StatefulSet{}).
 		Owns(&corev1.Service{})
 	// watch Istio virtual service
-	if os.Getenv("USE_ISTIO") == "true" {
+	if r.Config.Get().UseIstio {
 		virtualService := &unstructured.Unstructured{}
 		virtualService.SetAPIVersion("networking.istio.io/v1alpha3")
 		virtualService.SetKind("VirtualService")
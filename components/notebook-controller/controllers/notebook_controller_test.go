@@ -3,6 +3,9 @@ package controllers
 import (
 	"testing"
 
+	"github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/audit"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -84,3 +87,264 @@ func TestNbNameFromInvolvedObject(t *testing.T) {
 		})
 	}
 }
+
+func TestStatefulSetUpdateEvent(t *testing.T) {
+	culled := &v1beta1.Notebook{}
+	audit.AppendEvent(&culled.Status, audit.NewEvent(audit.ActionStop, audit.TriggerCuller,
+		audit.StateRunning, audit.StateStopped, ""))
+
+	tests := []struct {
+		name            string
+		notebook        *v1beta1.Notebook
+		oldState        string
+		newState        string
+		templateChanged bool
+		expectedOk      bool
+		expectedAction  string
+		expectedTrigger string
+	}{
+		{
+			name:            "spec change",
+			notebook:        &v1beta1.Notebook{},
+			oldState:        audit.StateRunning,
+			newState:        audit.StateRunning,
+			templateChanged: true,
+			expectedOk:      true,
+			expectedAction:  audit.ActionUpdate,
+			expectedTrigger: audit.TriggerSpecChange,
+		},
+		{
+			name:       "no change",
+			notebook:   &v1beta1.Notebook{},
+			oldState:   audit.StateRunning,
+			newState:   audit.StateRunning,
+			expectedOk: false,
+		},
+		{
+			name:            "stopped by user",
+			notebook:        &v1beta1.Notebook{},
+			oldState:        audit.StateRunning,
+			newState:        audit.StateStopped,
+			expectedOk:      true,
+			expectedAction:  audit.ActionStop,
+			expectedTrigger: audit.TriggerUserAnnotation,
+		},
+		{
+			name:       "stopped by culler",
+			notebook:   culled,
+			oldState:   audit.StateRunning,
+			newState:   audit.StateStopped,
+			expectedOk: false,
+		},
+		{
+			name:            "restarted by user",
+			notebook:        culled,
+			oldState:        audit.StateStopped,
+			newState:        audit.StateRunning,
+			expectedOk:      true,
+			expectedAction:  audit.ActionStart,
+			expectedTrigger: audit.TriggerUserAnnotation,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, ok := statefulSetUpdateEvent(test.notebook, test.oldState, test.newState, test.templateChanged)
+			if ok != test.expectedOk {
+				t.Fatalf("Got %v, Expected %v", ok, test.expectedOk)
+			}
+			if !ok {
+				return
+			}
+			if event.Action != test.expectedAction || event.Trigger != test.expectedTrigger {
+				t.Fatalf("Got %v/%v, Expected %v/%v", event.Action, event.Trigger,
+					test.expectedAction, test.expectedTrigger)
+			}
+		})
+	}
+}
+
+func TestProxySidecar(t *testing.T) {
+	cfg := config.Default()
+	cfg.ProxySidecars = map[string]config.ProxySidecarConfig{
+		"oauth2-proxy": {
+			Image:      "proxy:latest",
+			Args:       []string{"--prefix=$(NB_PREFIX)"},
+			Port:       4180,
+			HealthPath: "/ping",
+		},
+	}
+	newNotebook := func(annotations map[string]string, ports []corev1.ContainerPort) *v1beta1.Notebook {
+		return &v1beta1.Notebook{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "nb",
+				Namespace:   "ns",
+				Annotations: annotations,
+			},
+			Spec: v1beta1.NotebookSpec{
+				Template: v1beta1.NotebookTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "nb", Image: "code-server", Ports: ports}},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name               string
+		annotations        map[string]string
+		ports              []corev1.ContainerPort
+		expectedErr        bool
+		expectedContainers int
+		expectedTargetPort int
+	}{
+		{
+			name:               "no sidecar",
+			expectedContainers: 1,
+			expectedTargetPort: DefaultContainerPort,
+		},
+		{
+			name:               "configured sidecar",
+			annotations:        map[string]string{AnnotationProxySidecar: "oauth2-proxy"},
+			expectedContainers: 2,
+			expectedTargetPort: 4180,
+		},
+		{
+			name:        "unknown sidecar",
+			annotations: map[string]string{AnnotationProxySidecar: "nginx"},
+			expectedErr: true,
+		},
+		{
+			name:        "sidecar port used by the notebook",
+			annotations: map[string]string{AnnotationProxySidecar: "oauth2-proxy"},
+			ports:       []corev1.ContainerPort{{ContainerPort: 4180}},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := newNotebook(test.annotations, test.ports)
+			sidecar, err := proxySidecar(instance, cfg)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("Got error %v, Expected error %v", err, test.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			ss := generateStatefulSet(instance, cfg, sidecar)
+			containers := ss.Spec.Template.Spec.Containers
+			if len(containers) != test.expectedContainers {
+				t.Fatalf("Got %d containers, Expected %d", len(containers), test.expectedContainers)
+			}
+			svc := generateService(instance, sidecar)
+			if port := svc.Spec.Ports[0].TargetPort.IntValue(); port != test.expectedTargetPort {
+				t.Errorf("Got target port %d, Expected %d", port, test.expectedTargetPort)
+			}
+			if sidecar == nil {
+				return
+			}
+			proxy := containers[1]
+			env := map[string]string{}
+			for _, e := range proxy.Env {
+				env[e.Name] = e.Value
+			}
+			if env["NB_PREFIX"] != "/notebook/ns/nb" || env["UPSTREAM_PORT"] != "8888" {
+				t.Errorf("Unexpected proxy env %v", proxy.Env)
+			}
+			if proxy.ReadinessProbe == nil || proxy.ReadinessProbe.HTTPGet.Path != "/ping" {
+				t.Errorf("Unexpected proxy readiness probe %v", proxy.ReadinessProbe)
+			}
+		})
+	}
+}
+
+func TestDefaultProbes(t *testing.T) {
+	userProbe := &corev1.Probe{Handler: corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"true"}}}}
+
+	tests := []struct {
+		name                  string
+		image                 string
+		readinessProbe        *corev1.Probe
+		annotations           map[string]string
+		expectedReadinessPath string
+		expectedLivenessPath  string
+	}{
+		{
+			name:                  "jupyter image",
+			image:                 "kubeflownotebookswg/jupyter-scipy:v1.5.0",
+			expectedReadinessPath: "/notebook/ns/nb/api",
+		},
+		{
+			name:                  "behind a proxy sidecar",
+			image:                 "kubeflownotebookswg/codeserver-python:v1.5.0",
+			annotations:           map[string]string{AnnotationProxySidecar: "proxy"},
+			expectedReadinessPath: "/healthz",
+		},
+		{
+			name:           "user defined readiness probe",
+			image:          "kubeflownotebookswg/jupyter-scipy:v1.5.0",
+			readinessProbe: userProbe,
+		},
+		{
+			name:                  "configured liveness probe",
+			image:                 "example.com/jupyterlab:v1",
+			expectedReadinessPath: "/notebook/ns/nb/api",
+			expectedLivenessPath:  "/notebook/ns/nb/api",
+		},
+		{
+			name:  "unknown image",
+			image: "example.com/ide:latest",
+		},
+	}
+
+	cfg := config.Default()
+	cfg.ProxySidecars = map[string]config.ProxySidecarConfig{"proxy": {Image: "proxy", Port: 8080}}
+	cfg.Probes = append([]config.ProbeConfig{{
+		ImagePattern:        "^example.com/jupyterlab:",
+		ReadinessPath:       "/api",
+		LivenessPath:        "/api",
+		InitialDelaySeconds: 300,
+	}}, cfg.Probes...)
+	probePath := func(p *corev1.Probe) string {
+		if p == nil || p.HTTPGet == nil {
+			return ""
+		}
+		return p.HTTPGet.Path
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := &v1beta1.Notebook{
+				ObjectMeta: v1.ObjectMeta{Name: "nb", Namespace: "ns", Annotations: test.annotations},
+				Spec: v1beta1.NotebookSpec{
+					Template: v1beta1.NotebookTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{
+								{Name: "nb", Image: test.image, ReadinessProbe: test.readinessProbe},
+							},
+						},
+					},
+				},
+			}
+			sidecar, err := proxySidecar(instance, cfg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			container := generateStatefulSet(instance, cfg, sidecar).Spec.Template.Spec.Containers[0]
+			if test.readinessProbe != nil && (container.ReadinessProbe == nil || container.ReadinessProbe.Exec == nil) {
+				t.Errorf("User defined readiness probe was replaced by %v", container.ReadinessProbe)
+			}
+			if test.readinessProbe == nil && probePath(container.ReadinessProbe) != test.expectedReadinessPath {
+				t.Errorf("Got readiness path %q, Expected %q", probePath(container.ReadinessProbe), test.expectedReadinessPath)
+			}
+			if probePath(container.LivenessProbe) != test.expectedLivenessPath {
+				t.Errorf("Got liveness path %q, Expected %q", probePath(container.LivenessProbe), test.expectedLivenessPath)
+			}
+			if test.readinessProbe == nil && instance.Spec.Template.Spec.Containers[0].ReadinessProbe != nil {
+				t.Errorf("Default probes leaked into the Notebook")
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	controllermetrics "github.com/kubeflow/kubeflow/components/notebook-controller/pkg/metrics"
 
 	. "github.com/onsi/ginkgo"
@@ -76,6 +77,7 @@ var _ = BeforeSuite(func(done Done) {
 		Scheme:        k8sManager.GetScheme(),
 		Metrics:       controllermetrics.NewMetrics(k8sManager.GetClient()),
 		EventRecorder: k8sManager.GetEventRecorderFor("notebook-controller"),
+		Config:        config.NewStore(config.Default()),
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -18,11 +18,15 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
+	"github.com/kubeflow/kubeflow/components/common/namespaces"
 	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
 	nbv1alpha1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1alpha1"
 	nbv1beta1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/controllers"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/audit"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	controller_metrics "github.com/kubeflow/kubeflow/components/notebook-controller/pkg/metrics"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -47,23 +51,70 @@ func init() {
 }
 
 func main() {
-	var metricsAddr, leaderElectionNamespace string
+	var metricsAddr, leaderElectionNamespace, auditLogPath, configPath string
+	var watchNamespaces, namespaceSelector string
 	var enableLeaderElection bool
+	var configReloadInterval time.Duration
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "",
 		"Determines the namespace in which the leader election configmap will be created.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&auditLogPath, "audit-log-path", "",
+		"If set, lifecycle events of Notebooks are also appended to this file as JSON lines.")
+	flag.StringVar(&configPath, "config", "",
+		"Path of the controller configuration file. If unset, the configuration is read from ENV vars.")
+	flag.DurationVar(&configReloadInterval, "config-reload-interval", 30*time.Second,
+		"How often the configuration file is checked for changes.")
+	flag.StringVar(&watchNamespaces, "namespaces", "",
+		"Comma separated list of namespaces to watch. If unset, and no namespace-selector is given, all namespaces are watched.")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "",
+		"Label selector of the namespaces to watch, in addition to the ones of --namespaces. It is evaluated once at startup.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.Logger(true))
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+
+	var cfg *config.Config
+	var err error
+	if configPath != "" {
+		cfg, err = config.Load(configPath)
+	} else {
+		cfg, err = config.FromEnv()
+	}
+	if err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+	cfgStore := config.NewStore(cfg)
+
+	var auditSink *audit.Sink
+	if auditLogPath != "" {
+		auditSink, err = audit.OpenFileSink(auditLogPath)
+		if err != nil {
+			setupLog.Error(err, "unable to open audit log", "path", auditLogPath)
+			os.Exit(1)
+		}
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	options := ctrl.Options{
 		Scheme:                  scheme,
 		MetricsBindAddress:      metricsAddr,
 		LeaderElection:          enableLeaderElection,
 		LeaderElectionNamespace: leaderElectionNamespace,
 		LeaderElectionID:        "kubeflow-notebook-controller",
-	})
+	}
+	nsList, err := namespaces.Resolve(restConfig, watchNamespaces, namespaceSelector)
+	if err != nil {
+		setupLog.Error(err, "unable to resolve the namespaces to watch")
+		os.Exit(1)
+	}
+	if len(nsList) > 0 {
+		setupLog.Info("restricting the controller to namespaces", "namespaces", nsList)
+	}
+	namespaces.SetManagerOptions(&options, nsList)
+
+	mgr, err := ctrl.NewManager(restConfig, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -75,11 +126,25 @@ func main() {
 		Scheme:        mgr.GetScheme(),
 		Metrics:       controller_metrics.NewMetrics(mgr.GetClient()),
 		EventRecorder: mgr.GetEventRecorderFor("notebook-controller"),
+		Config:        cfgStore,
+		AuditSink:     auditSink,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Notebook")
 		os.Exit(1)
 	}
 
+	if configPath != "" {
+		if err = mgr.Add(&config.Watcher{
+			Path:     configPath,
+			Store:    cfgStore,
+			Interval: configReloadInterval,
+			Log:      ctrl.Log.WithName("config"),
+		}); err != nil {
+			setupLog.Error(err, "unable to watch configuration", "path", configPath)
+			os.Exit(1)
+		}
+	}
+
 	// uncomment when we need the conversion webhook.
 	// if err = (&nbv1beta1.Notebook{}).SetupWebhookWithManager(mgr); err != nil {
 	// 	setupLog.Error(err, "unable to create webhook", "webhook", "Captain")
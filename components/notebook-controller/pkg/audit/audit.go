@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Lifecycle actions recorded in the Notebook's status history.
+const (
+	ActionCreate = "Create"
+	ActionStop   = "Stop"
+	ActionStart  = "Start"
+	ActionUpdate = "Update"
+)
+
+// What triggered a lifecycle action.
+const (
+	// The culler decided that the Notebook was idle.
+	TriggerCuller = "Culler"
+	// A user set or removed the stop annotation on the Notebook.
+	TriggerUserAnnotation = "UserAnnotation"
+	// The Notebook's spec was created or changed.
+	TriggerSpecChange = "SpecChange"
+)
+
+// States of a Notebook as seen by the audit log.
+const (
+	StateRunning = "Running"
+	StateStopped = "Stopped"
+)
+
+// The maximum number of events kept in NotebookStatus.History. Older
+// events are dropped, but are still available in the sink if one is used.
+const MaxHistory = 20
+
+// NewEvent returns a lifecycle event stamped with the current time.
+func NewEvent(action, trigger, previousState, newState, message string) v1beta1.NotebookLifecycleEvent {
+	return v1beta1.NotebookLifecycleEvent{
+		Time:          metav1.Now(),
+		Action:        action,
+		Trigger:       trigger,
+		PreviousState: previousState,
+		NewState:      newState,
+		Message:       message,
+	}
+}
+
+// AppendEvent prepends the event to the status history, keeping at most
+// MaxHistory events.
+func AppendEvent(status *v1beta1.NotebookStatus, event v1beta1.NotebookLifecycleEvent) {
+	history := append([]v1beta1.NotebookLifecycleEvent{event}, status.History...)
+	if len(history) > MaxHistory {
+		history = history[:MaxHistory]
+	}
+	status.History = history
+}
+
+// LastState returns the state the Notebook was left in by the most recent
+// event of the history, or an empty string if there is none.
+func LastState(status v1beta1.NotebookStatus) string {
+	if len(status.History) == 0 {
+		return ""
+	}
+	return status.History[0].NewState
+}
+
+// Record is a single line of the JSON-lines sink.
+type Record struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	v1beta1.NotebookLifecycleEvent
+}
+
+// Sink writes lifecycle events as JSON lines. A nil *Sink discards
+// everything written to it.
+type Sink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewSink(w io.Writer) *Sink {
+	return &Sink{enc: json.NewEncoder(w)}
+}
+
+// OpenFileSink returns a Sink appending to the file at path, creating it if
+// needed.
+func OpenFileSink(path string) (*Sink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewSink(f), nil
+}
+
+func (s *Sink) Write(namespace, name string, event v1beta1.NotebookLifecycleEvent) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(Record{
+		Namespace:              namespace,
+		Name:                   name,
+		NotebookLifecycleEvent: event,
+	})
+}
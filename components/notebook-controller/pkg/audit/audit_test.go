@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
+)
+
+func TestAppendEvent(t *testing.T) {
+	status := &v1beta1.NotebookStatus{}
+	if LastState(*status) != "" {
+		t.Errorf("Expected empty state for empty history, got %s", LastState(*status))
+	}
+
+	AppendEvent(status, NewEvent(ActionCreate, TriggerSpecChange, "", StateRunning, ""))
+	AppendEvent(status, NewEvent(ActionStop, TriggerCuller, StateRunning, StateStopped, ""))
+	if len(status.History) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(status.History))
+	}
+	if status.History[0].Action != ActionStop {
+		t.Errorf("Expected newest event first, got %+v", status.History[0])
+	}
+	if LastState(*status) != StateStopped {
+		t.Errorf("Expected state %s, got %s", StateStopped, LastState(*status))
+	}
+
+	for i := 0; i < MaxHistory; i++ {
+		AppendEvent(status, NewEvent(ActionUpdate, TriggerSpecChange, StateStopped, StateStopped, ""))
+	}
+	if len(status.History) != MaxHistory {
+		t.Errorf("Expected history to be capped at %d, got %d", MaxHistory, len(status.History))
+	}
+}
+
+func TestSinkWrite(t *testing.T) {
+	var nilSink *Sink
+	if err := nilSink.Write("ns", "nb", NewEvent(ActionCreate, TriggerSpecChange, "", StateRunning, "")); err != nil {
+		t.Errorf("Unexpected error writing to nil sink: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	sink := NewSink(buf)
+	if err := sink.Write("ns", "nb", NewEvent(ActionStop, TriggerCuller, StateRunning, StateStopped, "idle")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := sink.Write("ns", "nb", NewEvent(ActionStart, TriggerUserAnnotation, StateStopped, StateRunning, "")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %s", len(lines), buf.String())
+	}
+	rec := Record{}
+	if err := json.Unmarshal(lines[0], &rec); err != nil {
+		t.Fatalf("Unexpected error decoding %s: %v", lines[0], err)
+	}
+	if rec.Namespace != "ns" || rec.Name != "nb" || rec.Action != ActionStop ||
+		rec.Trigger != TriggerCuller || rec.NewState != StateStopped {
+		t.Errorf("Unexpected record: %+v", rec)
+	}
+}
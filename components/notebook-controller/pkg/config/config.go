@@ -0,0 +1,369 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// The version of the configuration file understood by this controller.
+const (
+	APIVersion = "notebook-controller.kubeflow.org/v1alpha1"
+	Kind       = "NotebookControllerConfig"
+)
+
+// Default values, used for every field missing from the configuration file
+// or, when running without one, for every unset ENV var.
+// All the time numbers correspond to minutes.
+const (
+	DefaultIstioGateway       = "kubeflow/kubeflow-gateway"
+	DefaultClusterDomain      = "cluster.local"
+	DefaultIdleTime           = 1440 // One day
+	DefaultCullingCheckPeriod = 1
+)
+
+// Config is the configuration of the notebook controller.
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// UseIstio makes the controller create an Istio VirtualService for every
+	// Notebook. Changing it requires restarting the controller.
+	UseIstio bool `json:"useIstio"`
+	// IstioGateway is the gateway, in the form <namespace>/<name>, the
+	// VirtualServices are bound to.
+	IstioGateway string `json:"istioGateway,omitempty"`
+	// ClusterDomain is the DNS domain of the cluster.
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+	// AddFSGroup adds fsGroup: 100 to the security context of Notebook Pods
+	// which don't define one. Some platforms, like OpenShift, need this off.
+	AddFSGroup bool `json:"addFSGroup"`
+
+	Culling CullingConfig `json:"culling"`
+
+	// ProxySidecars are reverse proxies, by name, that Notebooks can ask to
+	// be injected in their Pod with the notebooks.kubeflow.org/proxy-sidecar
+	// annotation. They let web IDEs that can't serve under NB_PREFIX run
+	// without being rebuilt.
+	ProxySidecars map[string]ProxySidecarConfig `json:"proxySidecars,omitempty"`
+
+	// Probes are the default probes of notebook containers, by image. The
+	// first entry whose imagePattern matches the image of a container without
+	// probes is used.
+	Probes []ProbeConfig `json:"probes,omitempty"`
+}
+
+// ProbeConfig describes the default readiness and liveness probes of the
+// notebook containers whose image matches ImagePattern. Paths are relative
+// to NB_PREFIX, unless the Notebook runs behind a proxy sidecar, in which case
+// they are relative to the root of the IDE.
+type ProbeConfig struct {
+	// ImagePattern is a regular expression matched against the image.
+	ImagePattern string `json:"imagePattern"`
+	// No readiness or liveness probe is set if its path is empty.
+	ReadinessPath       string `json:"readinessPath,omitempty"`
+	LivenessPath        string `json:"livenessPath,omitempty"`
+	InitialDelaySeconds int32  `json:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int32  `json:"periodSeconds,omitempty"`
+	FailureThreshold    int32  `json:"failureThreshold,omitempty"`
+}
+
+// ProxySidecarConfig describes a reverse proxy container, which serves the
+// Notebook under NB_PREFIX and forwards requests to the IDE with the prefix
+// removed. The container gets the NB_PREFIX, UPSTREAM_PORT (the port of the
+// IDE) and PROXY_PORT ENV vars, which can be referenced in Args as
+// $(NB_PREFIX), $(UPSTREAM_PORT) and $(PROXY_PORT).
+type ProxySidecarConfig struct {
+	Image string   `json:"image"`
+	Args  []string `json:"args,omitempty"`
+	// Port the proxy listens on. The Notebook's Service targets this port.
+	Port int32 `json:"port"`
+	// HealthPath is served by the proxy and used for its readiness and
+	// liveness probes. No probe is set if it is empty.
+	HealthPath string                      `json:"healthPath,omitempty"`
+	Resources  corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// CullingConfig configures the stopping of idle Notebooks.
+type CullingConfig struct {
+	Enabled bool `json:"enabled"`
+	// Minutes without activity after which a Notebook is stopped.
+	IdleTimeMinutes int `json:"idleTimeMinutes,omitempty"`
+	// Minutes between two checks of the activity of a Notebook.
+	CheckPeriodMinutes int `json:"checkPeriodMinutes,omitempty"`
+}
+
+func (c CullingConfig) IdleTime() time.Duration {
+	return time.Duration(c.IdleTimeMinutes) * time.Minute
+}
+
+func (c CullingConfig) CheckPeriod() time.Duration {
+	return time.Duration(c.CheckPeriodMinutes) * time.Minute
+}
+
+// Default returns the configuration used when nothing is specified.
+func Default() *Config {
+	return &Config{
+		APIVersion:    APIVersion,
+		Kind:          Kind,
+		UseIstio:      false,
+		IstioGateway:  DefaultIstioGateway,
+		ClusterDomain: DefaultClusterDomain,
+		AddFSGroup:    true,
+		Culling: CullingConfig{
+			Enabled:            false,
+			IdleTimeMinutes:    DefaultIdleTime,
+			CheckPeriodMinutes: DefaultCullingCheckPeriod,
+		},
+		Probes: DefaultProbes(),
+	}
+}
+
+// DefaultProbes returns the probes used for the images shipped with Kubeflow.
+// Any image which isn't matched gets no default probes. They are readiness
+// probes only: a liveness probe would restart the images which start slowly.
+func DefaultProbes() []ProbeConfig {
+	return []ProbeConfig{
+		{
+			ImagePattern:     "jupyter",
+			ReadinessPath:    "/api",
+			PeriodSeconds:    10,
+			FailureThreshold: 6,
+		},
+		{
+			ImagePattern:     "codeserver|code-server",
+			ReadinessPath:    "/healthz",
+			PeriodSeconds:    10,
+			FailureThreshold: 6,
+		},
+		{
+			ImagePattern:     "rstudio",
+			ReadinessPath:    "/",
+			PeriodSeconds:    10,
+			FailureThreshold: 6,
+		},
+	}
+}
+
+// ProbeFor returns the first probe configuration matching the image, or nil.
+func (c *Config) ProbeFor(image string) *ProbeConfig {
+	for i := range c.Probes {
+		// The patterns are checked by Validate
+		if ok, _ := regexp.MatchString(c.Probes[i].ImagePattern, image); ok {
+			return &c.Probes[i]
+		}
+	}
+	return nil
+}
+
+// Validate returns an error describing the first invalid field of the
+// configuration.
+func (c *Config) Validate() error {
+	if c.APIVersion != APIVersion || c.Kind != Kind {
+		return fmt.Errorf("unsupported configuration %s/%s, expected %s/%s",
+			c.APIVersion, c.Kind, APIVersion, Kind)
+	}
+	if c.UseIstio && c.IstioGateway == "" {
+		return fmt.Errorf("istioGateway must be set when useIstio is true")
+	}
+	if c.ClusterDomain == "" {
+		return fmt.Errorf("clusterDomain must not be empty")
+	}
+	if c.Culling.IdleTimeMinutes <= 0 {
+		return fmt.Errorf("culling.idleTimeMinutes must be positive, got %d", c.Culling.IdleTimeMinutes)
+	}
+	if c.Culling.CheckPeriodMinutes <= 0 {
+		return fmt.Errorf("culling.checkPeriodMinutes must be positive, got %d", c.Culling.CheckPeriodMinutes)
+	}
+	for name, sidecar := range c.ProxySidecars {
+		if sidecar.Image == "" {
+			return fmt.Errorf("proxySidecars.%s.image must not be empty", name)
+		}
+		if sidecar.Port <= 0 || sidecar.Port > 65535 {
+			return fmt.Errorf("proxySidecars.%s.port must be a valid port, got %d", name, sidecar.Port)
+		}
+	}
+	for i, probe := range c.Probes {
+		if probe.ImagePattern == "" {
+			return fmt.Errorf("probes[%d].imagePattern must not be empty", i)
+		}
+		if _, err := regexp.Compile(probe.ImagePattern); err != nil {
+			return fmt.Errorf("probes[%d].imagePattern is invalid: %v", i, err)
+		}
+		if probe.InitialDelaySeconds < 0 || probe.PeriodSeconds < 0 || probe.FailureThreshold < 0 {
+			return fmt.Errorf("probes[%d] must not have negative values", i)
+		}
+	}
+	return nil
+}
+
+// Parse decodes a YAML or JSON configuration on top of the defaults and
+// validates it. Unknown fields, e.g. misspelled ones, are an error.
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+	// The defaults would hide a missing version.
+	cfg.APIVersion, cfg.Kind = "", ""
+	jsonData, err := yaml.ToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding configuration: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("error decoding configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// FromEnv builds the configuration from the ENV vars the controller used
+// before it had a configuration file: USE_ISTIO, ISTIO_GATEWAY,
+// CLUSTER_DOMAIN, ADD_FSGROUP, ENABLE_CULLING, IDLE_TIME and
+// CULLING_CHECK_PERIOD. Unlike before, invalid values are an error.
+func FromEnv() (*Config, error) {
+	return fromEnv(os.LookupEnv)
+}
+
+func fromEnv(lookup func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	var err error
+	if v, ok := lookup("USE_ISTIO"); ok && v != "" {
+		if cfg.UseIstio, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("USE_ISTIO should be a bool, got %q", v)
+		}
+	}
+	if v, ok := lookup("ISTIO_GATEWAY"); ok && v != "" {
+		cfg.IstioGateway = v
+	}
+	if v, ok := lookup("CLUSTER_DOMAIN"); ok && v != "" {
+		cfg.ClusterDomain = v
+	}
+	if v, ok := lookup("ADD_FSGROUP"); ok && v != "" {
+		if cfg.AddFSGroup, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("ADD_FSGROUP should be a bool, got %q", v)
+		}
+	}
+	if v, ok := lookup("ENABLE_CULLING"); ok && v != "" {
+		if cfg.Culling.Enabled, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("ENABLE_CULLING should be a bool, got %q", v)
+		}
+	}
+	if v, ok := lookup("IDLE_TIME"); ok && v != "" {
+		if cfg.Culling.IdleTimeMinutes, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("IDLE_TIME should be an int, got %q", v)
+		}
+	}
+	if v, ok := lookup("CULLING_CHECK_PERIOD"); ok && v != "" {
+		if cfg.Culling.CheckPeriodMinutes, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("CULLING_CHECK_PERIOD should be an int, got %q", v)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Store holds the current configuration. It is shared by the reconciler and
+// the Watcher, which replaces the configuration when the file changes.
+type Store struct {
+	mu  sync.RWMutex
+	cfg *Config
+}
+
+func NewStore(cfg *Config) *Store {
+	return &Store{cfg: cfg}
+}
+
+// Get returns the current configuration. It must not be modified.
+func (s *Store) Get() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+func (s *Store) Set(cfg *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+}
+
+// Watcher periodically re-reads the configuration file and updates the
+// Store when its content changes. Invalid configurations are logged and
+// ignored, so the controller keeps running with the last valid one.
+type Watcher struct {
+	Path     string
+	Store    *Store
+	Interval time.Duration
+	Log      logr.Logger
+
+	last []byte
+}
+
+// Start implements the manager.Runnable interface.
+func (w *Watcher) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			w.reload()
+		}
+	}
+}
+
+// NeedLeaderElection implements the manager.LeaderElectionRunnable
+// interface. Every replica needs an up to date configuration.
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+func (w *Watcher) reload() {
+	data, err := ioutil.ReadFile(w.Path)
+	if err != nil {
+		w.Log.Error(err, "unable to read configuration", "path", w.Path)
+		return
+	}
+	if bytes.Equal(data, w.last) {
+		return
+	}
+	w.last = data
+
+	cfg, err := Parse(data)
+	if err != nil {
+		w.Log.Error(err, "ignoring invalid configuration", "path", w.Path)
+		return
+	}
+	if reflect.DeepEqual(cfg, w.Store.Get()) {
+		return
+	}
+	if cfg.UseIstio != w.Store.Get().UseIstio {
+		w.Log.Info("useIstio changed, the controller must be restarted for it to take effect")
+	}
+	w.Store.Set(cfg)
+	w.Log.Info("Reloaded configuration", "path", w.Path)
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		testName string
+		data     string
+		valid    bool
+		check    func(*Config) bool
+	}{
+		{
+			testName: "Defaults are kept for missing fields",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+useIstio: true
+culling:
+  enabled: true
+`,
+			valid: true,
+			check: func(c *Config) bool {
+				return c.UseIstio && c.IstioGateway == DefaultIstioGateway && c.AddFSGroup &&
+					c.Culling.Enabled && c.Culling.IdleTime() == DefaultIdleTime*time.Minute
+			},
+		},
+		{
+			testName: "Every field set",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+useIstio: true
+istioGateway: istio-system/gateway
+clusterDomain: example.com
+addFSGroup: false
+culling:
+  enabled: true
+  idleTimeMinutes: 60
+  checkPeriodMinutes: 5
+`,
+			valid: true,
+			check: func(c *Config) bool {
+				return c.IstioGateway == "istio-system/gateway" && c.ClusterDomain == "example.com" &&
+					!c.AddFSGroup && c.Culling.IdleTime() == time.Hour &&
+					c.Culling.CheckPeriod() == 5*time.Minute
+			},
+		},
+		{
+			testName: "Missing version",
+			data:     `useIstio: true`,
+			valid:    false,
+		},
+		{
+			testName: "Unsupported version",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v2
+kind: NotebookControllerConfig
+`,
+			valid: false,
+		},
+		{
+			testName: "Negative idle time",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+culling:
+  idleTimeMinutes: -1
+`,
+			valid: false,
+		},
+		{
+			testName: "Proxy sidecar",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+proxySidecars:
+  oauth2-proxy:
+    image: quay.io/oauth2-proxy/oauth2-proxy:v7.0.1
+    args: ["--proxy-prefix=$(NB_PREFIX)"]
+    port: 4180
+    healthPath: /ping
+`,
+			valid: true,
+			check: func(c *Config) bool {
+				s, ok := c.ProxySidecars["oauth2-proxy"]
+				return ok && s.Port == 4180 && s.HealthPath == "/ping" && len(s.Args) == 1
+			},
+		},
+		{
+			testName: "Proxy sidecar without port",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+proxySidecars:
+  nginx:
+    image: nginx
+`,
+			valid: false,
+		},
+		{
+			testName: "Probes replace the defaults",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+probes:
+- imagePattern: "^example.com/rstudio:"
+  readinessPath: /
+  periodSeconds: 5
+`,
+			valid: true,
+			check: func(c *Config) bool {
+				p := c.ProbeFor("example.com/rstudio:v1")
+				return len(c.Probes) == 1 && p != nil && p.PeriodSeconds == 5 &&
+					c.ProbeFor("jupyter-scipy") == nil
+			},
+		},
+		{
+			testName: "Invalid probe pattern",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+probes:
+- imagePattern: "jupyter("
+`,
+			valid: false,
+		},
+		{
+			testName: "Wrong type",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+useIstio: maybe
+`,
+			valid: false,
+		},
+		{
+			testName: "Unknown field",
+			data: `
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+culling:
+  enabled: true
+  idleTime: 60
+`,
+			valid: false,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			cfg, err := Parse([]byte(c.data))
+			if (err == nil) != c.valid {
+				t.Fatalf("Expected valid=%v, got error: %v", c.valid, err)
+			}
+			if c.check != nil && !c.check(cfg) {
+				t.Errorf("Unexpected configuration: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	testCases := []struct {
+		testName string
+		env      map[string]string
+		valid    bool
+		check    func(*Config) bool
+	}{
+		{
+			testName: "No ENV vars",
+			env:      map[string]string{},
+			valid:    true,
+			check: func(c *Config) bool {
+				return !c.UseIstio && c.AddFSGroup && !c.Culling.Enabled &&
+					c.ClusterDomain == DefaultClusterDomain
+			},
+		},
+		{
+			testName: "Every ENV var set",
+			env: map[string]string{
+				"USE_ISTIO":            "true",
+				"ISTIO_GATEWAY":        "istio-system/gateway",
+				"CLUSTER_DOMAIN":       "example.com",
+				"ADD_FSGROUP":          "false",
+				"ENABLE_CULLING":       "true",
+				"IDLE_TIME":            "60",
+				"CULLING_CHECK_PERIOD": "5",
+			},
+			valid: true,
+			check: func(c *Config) bool {
+				return c.UseIstio && c.IstioGateway == "istio-system/gateway" &&
+					c.ClusterDomain == "example.com" && !c.AddFSGroup && c.Culling.Enabled &&
+					c.Culling.IdleTime() == time.Hour && c.Culling.CheckPeriod() == 5*time.Minute
+			},
+		},
+		{
+			testName: "IDLE_TIME is not an int",
+			env:      map[string]string{"IDLE_TIME": "one day"},
+			valid:    false,
+		},
+		{
+			testName: "CULLING_CHECK_PERIOD is zero",
+			env:      map[string]string{"CULLING_CHECK_PERIOD": "0"},
+			valid:    false,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			cfg, err := fromEnv(func(key string) (string, bool) {
+				v, ok := c.env[key]
+				return v, ok
+			})
+			if (err == nil) != c.valid {
+				t.Fatalf("Expected valid=%v, got error: %v", c.valid, err)
+			}
+			if c.check != nil && !c.check(cfg) {
+				t.Errorf("Unexpected configuration: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notebook-controller-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	store := NewStore(Default())
+	w := &Watcher{Path: path, Store: store, Log: zap.Logger(true)}
+
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w.reload()
+	}
+
+	write(`
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+culling:
+  enabled: true
+`)
+	if !store.Get().Culling.Enabled {
+		t.Errorf("Expected the configuration to be reloaded")
+	}
+
+	write(`
+apiVersion: notebook-controller.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+culling:
+  enabled: false
+  idleTimeMinutes: 0
+`)
+	if !store.Get().Culling.Enabled {
+		t.Errorf("Expected the invalid configuration to be ignored")
+	}
+}
@@ -4,10 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/metrics"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -18,14 +17,6 @@ var client = &http.Client{
 	Timeout: time.Second * 10,
 }
 
-// The constants with name 'DEFAULT_{ENV_Var}' are the default values to be
-// used, if the respective ENV vars are not present.
-// All the time numbers correspond to minutes.
-const DEFAULT_IDLE_TIME = "1440" // One day
-const DEFAULT_CULLING_CHECK_PERIOD = "1"
-const DEFAULT_ENABLE_CULLING = "false"
-const DEFAULT_CLUSTER_DOMAIN = "cluster.local"
-
 // When a Resource should be stopped/culled, then the controller should add this
 // annotation in the Resource's Metadata. Then, inside the reconcile loop,
 // the controller must check if this annotation is set and then apply the
@@ -43,48 +34,15 @@ type NotebookStatus struct {
 	Kernels      int    `json:"kernels"`
 }
 
-// Some Utility Functions
-func getEnvDefault(variable string, defaultVal string) string {
-	envVar := os.Getenv(variable)
-	if len(envVar) == 0 {
-		return defaultVal
-	}
-	return envVar
-}
-
 // Time / Frequency Utility functions
 func createTimestamp() string {
 	now := time.Now()
 	return now.Format(time.RFC3339)
 }
 
-func GetRequeueTime() time.Duration {
+func GetRequeueTime(cfg *config.Config) time.Duration {
 	// The frequency in which we check if the Pod needs culling
-	// Uses ENV var: CULLING_CHECK_PERIOD
-	cullingPeriod := getEnvDefault(
-		"CULLING_CHECK_PERIOD", DEFAULT_CULLING_CHECK_PERIOD)
-	realCullingPeriod, err := strconv.Atoi(cullingPeriod)
-	if err != nil {
-		log.Info(fmt.Sprintf(
-			"Culling Period should be Int. Got '%s'. Using default value.",
-			cullingPeriod))
-		realCullingPeriod, _ = strconv.Atoi(DEFAULT_CULLING_CHECK_PERIOD)
-	}
-
-	return time.Duration(realCullingPeriod) * time.Minute
-}
-
-func getMaxIdleTime() time.Duration {
-	idleTime := getEnvDefault("IDLE_TIME", DEFAULT_IDLE_TIME)
-	realIdleTime, err := strconv.Atoi(idleTime)
-	if err != nil {
-		log.Info(fmt.Sprintf(
-			"IDLE_TIME should be Int. Got %s instead. Using default value.",
-			idleTime))
-		realIdleTime, _ = strconv.Atoi(DEFAULT_IDLE_TIME)
-	}
-
-	return time.Minute * time.Duration(realIdleTime)
+	return cfg.Culling.CheckPeriod()
 }
 
 // Stop Annotation handling functions
@@ -135,9 +93,8 @@ func StopAnnotationIsSet(meta metav1.ObjectMeta) bool {
 }
 
 // Culling Logic
-func getNotebookApiStatus(nm, ns string) *NotebookStatus {
+func getNotebookApiStatus(nm, ns, domain string) *NotebookStatus {
 	// Get the Notebook Status from the Server's /api/status endpoint
-	domain := getEnvDefault("CLUSTER_DOMAIN", DEFAULT_CLUSTER_DOMAIN)
 	url := fmt.Sprintf(
 		"http://%s.%s.svc.%s/notebook/%s/%s/api/status",
 		nm, ns, domain, ns, nm)
@@ -168,7 +125,7 @@ func getNotebookApiStatus(nm, ns string) *NotebookStatus {
 	return status
 }
 
-func notebookIsIdle(nm, ns string, status *NotebookStatus) bool {
+func notebookIsIdle(nm, ns string, status *NotebookStatus, maxIdleTime time.Duration) bool {
 	// Being idle means that the Notebook can be culled
 	if status == nil {
 		return false
@@ -181,17 +138,17 @@ func notebookIsIdle(nm, ns string, status *NotebookStatus) bool {
 		return false
 	}
 
-	timeCap := lastActivity.Add(getMaxIdleTime())
+	timeCap := lastActivity.Add(maxIdleTime)
 	if time.Now().After(timeCap) {
 		return true
 	}
 	return false
 }
 
-func NotebookNeedsCulling(nbMeta metav1.ObjectMeta) bool {
-	if getEnvDefault("ENABLE_CULLING", DEFAULT_ENABLE_CULLING) != "true" {
-		log.Info("Culling of idle Pods is Disabled. To enable it set the " +
-			"ENV Var 'ENABLE_CULLING=true'")
+func NotebookNeedsCulling(nbMeta metav1.ObjectMeta, cfg *config.Config) bool {
+	if !cfg.Culling.Enabled {
+		log.Info("Culling of idle Pods is Disabled. To enable it set " +
+			"'culling.enabled: true' in the controller configuration")
 		return false
 	}
 
@@ -201,6 +158,6 @@ func NotebookNeedsCulling(nbMeta metav1.ObjectMeta) bool {
 		return false
 	}
 
-	notebookStatus := getNotebookApiStatus(nm, ns)
-	return notebookIsIdle(nm, ns, notebookStatus)
+	notebookStatus := getNotebookApiStatus(nm, ns, cfg.ClusterDomain)
+	return notebookIsIdle(nm, ns, notebookStatus, cfg.Culling.IdleTime())
 }
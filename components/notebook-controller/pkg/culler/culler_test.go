@@ -1,10 +1,10 @@
 package culler
 
 import (
-	"os"
 	"testing"
 	"time"
 
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -137,13 +137,12 @@ func TestNotebookIsIdle(t *testing.T) {
 	testCases := []struct {
 		testName string
 		status   *NotebookStatus
-		env      map[string]string
+		idleTime int
 		result   bool
 	}{
 		{
 			testName: "No Notebook Status received from Server",
 			status:   nil,
-			env:      map[string]string{},
 			result:   false,
 		},
 		{
@@ -151,7 +150,6 @@ func TestNotebookIsIdle(t *testing.T) {
 			status: &NotebookStatus{
 				LastActivity: "",
 			},
-			env:    map[string]string{},
 			result: false,
 		},
 		{
@@ -159,7 +157,6 @@ func TestNotebookIsIdle(t *testing.T) {
 			status: &NotebookStatus{
 				LastActivity: "should-fail",
 			},
-			env:    map[string]string{},
 			result: false,
 		},
 		{
@@ -167,7 +164,6 @@ func TestNotebookIsIdle(t *testing.T) {
 			status: &NotebookStatus{
 				LastActivity: "1996-04-11T00:00:00Z",
 			},
-			env:    map[string]string{},
 			result: true,
 		},
 		{
@@ -175,7 +171,6 @@ func TestNotebookIsIdle(t *testing.T) {
 			status: &NotebookStatus{
 				LastActivity: time.Now().Format(time.RFC3339),
 			},
-			env:    map[string]string{},
 			result: false,
 		},
 		{
@@ -183,31 +178,27 @@ func TestNotebookIsIdle(t *testing.T) {
 			status: &NotebookStatus{
 				LastActivity: time.Now().Add(-6 * time.Minute).Format(time.RFC3339),
 			},
-			env: map[string]string{
-				"IDLE_TIME": "5",
-			},
-			result: true,
+			idleTime: 5,
+			result:   true,
 		},
 		{
 			testName: "LastActivity until Now is 1 minute LESS than the deadline",
 			status: &NotebookStatus{
 				LastActivity: time.Now().Add(-4 * time.Minute).Format(time.RFC3339),
 			},
-			env: map[string]string{
-				"IDLE_TIME": "5",
-			},
-			result: false,
+			idleTime: 5,
+			result:   false,
 		},
 	}
 
 	for _, c := range testCases {
 		t.Run(c.testName, func(t *testing.T) {
-			// Apply env variables
-			for envVar, val := range c.env {
-				os.Setenv(envVar, val)
+			cfg := config.Default()
+			if c.idleTime != 0 {
+				cfg.Culling.IdleTimeMinutes = c.idleTime
 			}
 
-			if notebookIsIdle("test", "kubeflow", c.status) != c.result {
+			if notebookIsIdle("test", "kubeflow", c.status, cfg.Culling.IdleTime()) != c.result {
 				t.Errorf("Wrong result for case status: %+v", c.status)
 			}
 		})
@@ -218,22 +209,18 @@ func TestNotebookNeedsCulling(t *testing.T) {
 	testCases := []struct {
 		testName string
 		meta     metav1.ObjectMeta
-		env      map[string]string
+		enabled  bool
 		result   bool
 	}{
 		{
-			testName: "ENABLE_CULLING disabled",
-			env: map[string]string{
-				"ENABLE_CULLING": "false",
-			},
-			meta:   metav1.ObjectMeta{},
-			result: false,
+			testName: "Culling disabled",
+			enabled:  false,
+			meta:     metav1.ObjectMeta{},
+			result:   false,
 		},
 		{
 			testName: "Stop Annotation already set",
-			env: map[string]string{
-				"ENABLE_CULLING": "true",
-			},
+			enabled:  true,
 			meta: metav1.ObjectMeta{
 				Annotations: map[string]string{
 					STOP_ANNOTATION: time.Now().Format(time.RFC3339),
@@ -245,12 +232,10 @@ func TestNotebookNeedsCulling(t *testing.T) {
 
 	for _, c := range testCases {
 		t.Run(c.testName, func(t *testing.T) {
-			// Apply env variables
-			for envVar, val := range c.env {
-				os.Setenv(envVar, val)
-			}
+			cfg := config.Default()
+			cfg.Culling.Enabled = c.enabled
 
-			if NotebookNeedsCulling(c.meta) != c.result {
+			if NotebookNeedsCulling(c.meta, cfg) != c.result {
 				t.Errorf("Wrong result for case: %+v", c)
 			}
 		})
@@ -17,6 +17,7 @@ package v1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -29,21 +30,125 @@ type Plugin struct {
 	Spec *runtime.RawExtension `json:"spec,omitempty"`
 }
 
+// Contributor is a user or group given access to the namespace of a Profile.
+type Contributor struct {
+	// The contributor, of kind User or Group
+	Subject rbacv1.Subject `json:"subject"`
+	// The role of the contributor in the namespace, one of the roles of the
+	// role catalog of the controller, by default admin, edit or view
+	Role string `json:"role"`
+}
+
+// ProfileServiceAccount is a ServiceAccount created in the namespace of a
+// Profile, in addition to default-editor and default-viewer.
+type ProfileServiceAccount struct {
+	// Name of the ServiceAccount
+	Name string `json:"name"`
+	// The ClusterRole the ServiceAccount is bound to in the namespace, one of
+	// the ClusterRoles of the role catalog of the controller
+	ClusterRole string `json:"clusterRole"`
+}
+
+// ProfileNetworkPolicy configures the default NetworkPolicies of the
+// namespace of a Profile.
+type ProfileNetworkPolicy struct {
+	// Whether the default NetworkPolicies are created, overriding the
+	// default of the controller
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Namespaces allowed to send traffic to the namespace, in addition to
+	// the ones allowed by the controller
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+	// Egress rules of the namespace. All egress traffic is allowed when empty.
+	// +optional
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
+// DeletionPolicy is what happens to the namespace of a Profile when the
+// Profile is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain;Orphan
+type DeletionPolicy string
+
+const (
+	// The namespace and everything in it are deleted
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// The namespace, its workloads and PVCs are kept, the objects managed by
+	// the controller are deleted and the plugins revoked
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// The namespace and the objects managed by the controller are kept
+	// without their owner references, the plugins aren't revoked
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
 type ProfileCondition struct {
 	Type    string `json:"type,omitempty"`
 	Status  string `json:"status,omitempty" description:"status of the condition, one of True, False, Unknown"`
 	Message string `json:"message,omitempty"`
+	// A machine readable reason for the last transition of the condition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// The last time the status of the condition changed
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// The generation of the Profile the condition was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 // ProfileSpec defines the desired state of Profile
 type ProfileSpec struct {
-	// The profile owner
+	// The profile owner. Deprecated: use owners, setting owner is the same
+	// as listing it first in owners.
+	// +optional
 	Owner rbacv1.Subject `json:"owner,omitempty"`
 
+	// The owners of the profile, of kind User or Group. The first one is the
+	// primary owner, recorded in the owner annotation of the namespace.
+	// +optional
+	Owners []rbacv1.Subject `json:"owners,omitempty"`
+
+	// Users and groups given access to the namespace. Their RoleBindings
+	// and AuthorizationPolicies are managed by the controller.
+	// +optional
+	Contributors []Contributor `json:"contributors,omitempty"`
+
+	// Additional ServiceAccounts of the namespace, e.g. to run pipelines.
+	// Plugins can bind them to cloud identities.
+	// +optional
+	ServiceAccounts []ProfileServiceAccount `json:"serviceAccounts,omitempty"`
+
 	Plugins []Plugin `json:"plugins,omitempty"`
 
 	// Resourcequota that will be applied to target namespace
 	ResourceQuotaSpec v1.ResourceQuotaSpec `json:"resourceQuotaSpec,omitempty"`
+
+	// LimitRange applied to the target namespace. When neither the profile
+	// nor its template set it, default requests and limits are applied for
+	// the resources constrained by the quota.
+	// +optional
+	LimitRangeSpec *v1.LimitRangeSpec `json:"limitRangeSpec,omitempty"`
+
+	// Default NetworkPolicies of the target namespace
+	// +optional
+	NetworkPolicy *ProfileNetworkPolicy `json:"networkPolicy,omitempty"`
+
+	// The ProfileTemplate supplying the defaults of the profile
+	// +optional
+	TemplateRef *ProfileTemplateReference `json:"templateRef,omitempty"`
+
+	// Take ownership of the namespace when it already exists without being
+	// owned by the profile. An administrator must approve the adoption by
+	// annotating the namespace with profiles.kubeflow.org/adoption-approved
+	// set to the name of the profile. Adopted namespaces are released, not
+	// deleted, when the profile is deleted.
+	// +optional
+	AdoptExistingNamespace bool `json:"adoptExistingNamespace,omitempty"`
+
+	// What happens to the namespace when the profile is deleted, Delete by
+	// default
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 const (
@@ -52,9 +157,45 @@ const (
 	ProfileUnknown = "Unknown"
 )
 
+// Types of the conditions of a Profile. Ready is True when all the others are.
+const (
+	ProfileReady   = "Ready"
+	NamespaceReady = "NamespaceReady"
+	RBACReady      = "RBACReady"
+	QuotaReady     = "QuotaReady"
+	PluginsReady   = "PluginsReady"
+	TemplateReady  = "TemplateReady"
+)
+
+// PluginStatus is the result of the last application of a plugin.
+type PluginStatus struct {
+	Kind string `json:"kind"`
+	// One of True, False, Unknown
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// The last time the status of the plugin changed
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
 // ProfileStatus defines the observed state of Profile
 type ProfileStatus struct {
+	// Conditions has one entry per condition type
 	Conditions []ProfileCondition `json:"conditions,omitempty"`
+	// The generation of the Profile the status was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Plugins has one entry per plugin of the spec
+	// +optional
+	Plugins []PluginStatus `json:"plugins,omitempty"`
+	// The hard limits and usage of the ResourceQuota of the namespace
+	// +optional
+	Quota *v1.ResourceQuotaStatus `json:"quota,omitempty"`
+	// The plugins applied to the namespace, as they were applied. They are
+	// revoked when they are removed from the spec or the profile is deleted,
+	// and removed from the list once revoked.
+	// +optional
+	AppliedPlugins []Plugin `json:"appliedPlugins,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ProfileTemplateReference refers to the ProfileTemplate of a Profile.
+type ProfileTemplateReference struct {
+	// The name of the ProfileTemplate
+	Name string `json:"name"`
+}
+
+// PodDefaultTemplate is a PodDefault created in the namespace of the
+// Profiles using the template.
+type PodDefaultTemplate struct {
+	// The name of the PodDefault
+	Name string `json:"name"`
+	// The spec of the PodDefault, as defined by the admission-webhook
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Spec *runtime.RawExtension `json:"spec"`
+}
+
+// ProfileTemplateSpec defines the defaults of the Profiles using the template.
+// Profiles override them with their own ResourceQuotaSpec and plugins.
+type ProfileTemplateSpec struct {
+	// Resourcequota applied to the namespaces. The hard limits of a
+	// Profile are merged with these.
+	// +optional
+	ResourceQuotaSpec v1.ResourceQuotaSpec `json:"resourceQuotaSpec,omitempty"`
+
+	// Plugins applied to the Profiles which don't have one of the same kind
+	// +optional
+	Plugins []Plugin `json:"plugins,omitempty"`
+
+	// Labels added to the namespaces
+	// +optional
+	NamespaceLabels map[string]string `json:"namespaceLabels,omitempty"`
+
+	// Annotations added to the namespaces
+	// +optional
+	NamespaceAnnotations map[string]string `json:"namespaceAnnotations,omitempty"`
+
+	// LimitRange applied to the namespaces
+	// +optional
+	LimitRangeSpec *v1.LimitRangeSpec `json:"limitRangeSpec,omitempty"`
+
+	// PodDefaults created in the namespaces
+	// +optional
+	PodDefaults []PodDefaultTemplate `json:"podDefaults,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=profiletemplates,scope=Cluster
+
+// ProfileTemplate is the Schema for the profiletemplates API
+type ProfileTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProfileTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ProfileTemplateList contains a list of ProfileTemplate
+type ProfileTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProfileTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProfileTemplate{}, &ProfileTemplateList{})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// Annotation of a v1beta1 Profile holding the fields of the v1 Profile it
+// was converted from which v1beta1 doesn't have, so that converting it back
+// to v1 doesn't lose them.
+const V1FIELDSANNOTATION = "profiles.kubeflow.org/v1-fields"
+
+// v1Fields is the value of the V1FIELDSANNOTATION annotation: the spec and
+// status of the v1 Profile, without the fields v1beta1 has.
+type v1Fields struct {
+	Spec   profilev1.ProfileSpec   `json:"spec"`
+	Status profilev1.ProfileStatus `json:"status"`
+}
+
+var _ conversion.Convertible = &Profile{}
+
+// ConvertTo converts this Profile to the hub version, v1.
+func (src *Profile) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*profilev1.Profile)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	fields := v1Fields{}
+	if raw, ok := dst.Annotations[V1FIELDSANNOTATION]; ok {
+		// The annotation may have been edited by v1beta1 clients, the fields
+		// are lost rather than failing every request on the profile
+		if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+			fields = v1Fields{}
+		}
+		delete(dst.Annotations, V1FIELDSANNOTATION)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+	dst.Spec = fields.Spec
+	dst.Spec.Owner = src.Spec.Owner
+	dst.Spec.Owners = append([]rbacv1.Subject(nil), src.Spec.Owners...)
+	dst.Spec.Contributors = nil
+	for _, c := range src.Spec.Contributors {
+		dst.Spec.Contributors = append(dst.Spec.Contributors, profilev1.Contributor(c))
+	}
+	dst.Spec.Plugins = nil
+	for _, p := range src.Spec.Plugins {
+		dst.Spec.Plugins = append(dst.Spec.Plugins, profilev1.Plugin(*p.DeepCopy()))
+	}
+	dst.Spec.ResourceQuotaSpec = *src.Spec.ResourceQuotaSpec.DeepCopy()
+
+	dst.Status = fields.Status
+	dst.Status.Conditions = nil
+	for _, c := range src.Status.Conditions {
+		condition := profilev1.ProfileCondition{Type: c.Type, Status: c.Status, Message: c.Message}
+		// The fields v1beta1 doesn't have are kept while the condition is unchanged
+		for _, saved := range fields.Status.Conditions {
+			if saved.Type == c.Type && saved.Status == c.Status && saved.Message == c.Message {
+				condition = saved
+			}
+		}
+		dst.Status.Conditions = append(dst.Status.Conditions, condition)
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub version, v1, to this Profile. The fields
+// v1beta1 doesn't have are saved in the V1FIELDSANNOTATION annotation.
+func (dst *Profile) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*profilev1.Profile)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = ProfileSpec{
+		Owner:             src.Spec.Owner,
+		Owners:            append([]rbacv1.Subject(nil), src.Spec.Owners...),
+		ResourceQuotaSpec: *src.Spec.ResourceQuotaSpec.DeepCopy(),
+	}
+	for _, c := range src.Spec.Contributors {
+		dst.Spec.Contributors = append(dst.Spec.Contributors, Contributor(c))
+	}
+	for _, p := range src.Spec.Plugins {
+		dst.Spec.Plugins = append(dst.Spec.Plugins, Plugin(*p.DeepCopy()))
+	}
+	dst.Status = ProfileStatus{}
+	for _, c := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions,
+			ProfileCondition{Type: c.Type, Status: c.Status, Message: c.Message})
+	}
+
+	fields := v1Fields{Spec: *src.Spec.DeepCopy(), Status: *src.Status.DeepCopy()}
+	fields.Spec.Owner = rbacv1.Subject{}
+	fields.Spec.Owners = nil
+	fields.Spec.Contributors = nil
+	fields.Spec.Plugins = nil
+	fields.Spec.ResourceQuotaSpec = v1.ResourceQuotaSpec{}
+	// Only the conditions with fields v1beta1 doesn't have are saved
+	fields.Status.Conditions = nil
+	for _, c := range src.Status.Conditions {
+		if c != (profilev1.ProfileCondition{Type: c.Type, Status: c.Status, Message: c.Message}) {
+			fields.Status.Conditions = append(fields.Status.Conditions, c)
+		}
+	}
+	if apiequality.Semantic.DeepEqual(fields, v1Fields{}) {
+		return nil
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[V1FIELDSANNOTATION] = string(raw)
+	return nil
+}
@@ -0,0 +1,133 @@
+package v1beta1
+
+import (
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func v1Profile() *profilev1.Profile {
+	enabled := true
+	return &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "alice",
+			Annotations: map[string]string{"team": "ml"},
+			Generation:  3,
+		},
+		Spec: profilev1.ProfileSpec{
+			Owner:  rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+			Owners: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "team-a"}},
+			Contributors: []profilev1.Contributor{
+				{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "edit"},
+			},
+			ServiceAccounts: []profilev1.ProfileServiceAccount{{Name: "pipeline-runner", ClusterRole: "kubeflow-edit"}},
+			Plugins: []profilev1.Plugin{{
+				TypeMeta: metav1.TypeMeta{Kind: "WorkloadIdentity"},
+				Spec:     &runtime.RawExtension{Raw: []byte(`{"gcpServiceAccount":"alice@project.iam.gserviceaccount.com"}`)},
+			}},
+			ResourceQuotaSpec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+			NetworkPolicy:          &profilev1.ProfileNetworkPolicy{Enabled: &enabled},
+			TemplateRef:            &profilev1.ProfileTemplateReference{Name: "team"},
+			AdoptExistingNamespace: true,
+			DeletionPolicy:         profilev1.DeletionPolicyRetain,
+		},
+		Status: profilev1.ProfileStatus{
+			Conditions: []profilev1.ProfileCondition{{
+				Type:               profilev1.ProfileReady,
+				Status:             string(corev1.ConditionTrue),
+				Reason:             "Reconciled",
+				LastTransitionTime: metav1.Unix(1600000000, 0),
+				ObservedGeneration: 3,
+			}},
+			ObservedGeneration: 3,
+			Plugins:            []profilev1.PluginStatus{{Kind: "WorkloadIdentity", Status: "True"}},
+		},
+	}
+}
+
+func TestConvertV1RoundTrip(t *testing.T) {
+	original := v1Profile()
+	beta := &Profile{}
+	if err := beta.ConvertFrom(original.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if beta.Spec.Owner != original.Spec.Owner || len(beta.Spec.Owners) != 1 ||
+		len(beta.Spec.Contributors) != 1 || len(beta.Spec.Plugins) != 1 {
+		t.Errorf("Expect:\n%v; Output:\n%v", original.Spec, beta.Spec)
+	}
+	if _, ok := beta.Annotations[V1FIELDSANNOTATION]; !ok {
+		t.Errorf("Expect:\n%v annotation; Output:\n%v", V1FIELDSANNOTATION, beta.Annotations)
+	}
+	converted := &profilev1.Profile{}
+	if err := beta.ConvertTo(converted); err != nil {
+		t.Fatal(err)
+	}
+	if !apiequality.Semantic.DeepEqual(converted, original) {
+		t.Errorf("Expect:\n%v; Output:\n%v", original, converted)
+	}
+}
+
+func TestConvertV1beta1RoundTrip(t *testing.T) {
+	original := &Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec: ProfileSpec{
+			Owner:  rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+			Owners: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "bob@example.com"}},
+			Contributors: []Contributor{
+				{Subject: rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "team-b"}, Role: "view"},
+			},
+		},
+		Status: ProfileStatus{Conditions: []ProfileCondition{{Type: ProfileSucceed, Status: "True"}}},
+	}
+	hub := &profilev1.Profile{}
+	if err := original.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	converted := &Profile{}
+	if err := converted.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !apiequality.Semantic.DeepEqual(converted, original) {
+		t.Errorf("Expect:\n%v; Output:\n%v", original, converted)
+	}
+}
+
+func TestConvertV1beta1Update(t *testing.T) {
+	beta := &Profile{}
+	if err := beta.ConvertFrom(v1Profile()); err != nil {
+		t.Fatal(err)
+	}
+	// A v1beta1 client changes the contributors, and the status changes
+	beta.Spec.Contributors = nil
+	beta.Status.Conditions[0].Status = string(corev1.ConditionFalse)
+	converted := &profilev1.Profile{}
+	if err := beta.ConvertTo(converted); err != nil {
+		t.Fatal(err)
+	}
+	if len(converted.Spec.Contributors) != 0 {
+		t.Errorf("Expect:\nno contributors; Output:\n%v", converted.Spec.Contributors)
+	}
+	if converted.Spec.DeletionPolicy != profilev1.DeletionPolicyRetain || len(converted.Spec.ServiceAccounts) != 1 {
+		t.Errorf("Expect:\nv1 fields kept; Output:\n%v", converted.Spec)
+	}
+	if c := converted.Status.Conditions[0]; c.Reason != "" || c.Status != string(corev1.ConditionFalse) {
+		t.Errorf("Expect:\nchanged condition without v1 fields; Output:\n%v", c)
+	}
+	if _, ok := converted.Annotations[V1FIELDSANNOTATION]; ok {
+		t.Errorf("Expect:\nno %v annotation; Output:\n%v", V1FIELDSANNOTATION, converted.Annotations)
+	}
+
+	// An invalid annotation only loses the v1 fields
+	beta.Annotations[V1FIELDSANNOTATION] = "{"
+	if err := beta.ConvertTo(converted); err != nil || converted.Spec.DeletionPolicy != "" {
+		t.Errorf("Expect:\nv1 fields lost; Output:\n%v %v", err, converted.Spec)
+	}
+}
@@ -29,6 +29,15 @@ type Plugin struct {
 	Spec *runtime.RawExtension `json:"spec,omitempty"`
 }
 
+// Contributor is a user or group given access to the namespace of a Profile.
+type Contributor struct {
+	// The contributor, of kind User or Group
+	Subject rbacv1.Subject `json:"subject"`
+	// The role of the contributor in the namespace, one of the roles of the
+	// role catalog of the controller, by default admin, edit or view
+	Role string `json:"role"`
+}
+
 type ProfileCondition struct {
 	Type    string `json:"type,omitempty"`
 	Status  string `json:"status,omitempty" description:"status of the condition, one of True, False, Unknown"`
@@ -37,9 +46,21 @@ type ProfileCondition struct {
 
 // ProfileSpec defines the desired state of Profile
 type ProfileSpec struct {
-	// The profile owner
+	// The profile owner. Deprecated: use owners, setting owner is the same
+	// as listing it first in owners.
+	// +optional
 	Owner rbacv1.Subject `json:"owner,omitempty"`
 
+	// The owners of the profile, of kind User or Group. The first one is the
+	// primary owner, recorded in the owner annotation of the namespace.
+	// +optional
+	Owners []rbacv1.Subject `json:"owners,omitempty"`
+
+	// Users and groups given access to the namespace. Their RoleBindings
+	// and AuthorizationPolicies are managed by the controller.
+	// +optional
+	Contributors []Contributor `json:"contributors,omitempty"`
+
 	Plugins []Plugin `json:"plugins,omitempty"`
 
 	// Resourcequota that will be applied to target namespace
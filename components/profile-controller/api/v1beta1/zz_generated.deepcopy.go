@@ -20,9 +20,26 @@ limitations under the License.
 package v1beta1
 
 import (
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Contributor) DeepCopyInto(out *Contributor) {
+	*out = *in
+	out.Subject = in.Subject
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Contributor.
+func (in *Contributor) DeepCopy() *Contributor {
+	if in == nil {
+		return nil
+	}
+	out := new(Contributor)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Plugin) DeepCopyInto(out *Plugin) {
 	*out = *in
@@ -122,6 +139,16 @@ func (in *ProfileList) DeepCopyObject() runtime.Object {
 func (in *ProfileSpec) DeepCopyInto(out *ProfileSpec) {
 	*out = *in
 	out.Owner = in.Owner
+	if in.Owners != nil {
+		in, out := &in.Owners, &out.Owners
+		*out = make([]rbacv1.Subject, len(*in))
+		copy(*out, *in)
+	}
+	if in.Contributors != nil {
+		in, out := &in.Contributors, &out.Contributors
+		*out = make([]Contributor, len(*in))
+		copy(*out, *in)
+	}
 	if in.Plugins != nil {
 		in, out := &in.Plugins, &out.Plugins
 		*out = make([]Plugin, len(*in))
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Path of the validating admission webhook of Profiles and ProfileTemplates
+const PROFILEVALIDATINGWEBHOOKPATH = "/validate-kubeflow-org-v1-profile"
+
+// +kubebuilder:webhook:path=/validate-kubeflow-org-v1-profile,mutating=false,failurePolicy=fail,groups=kubeflow.org,resources=profiles;profiletemplates,verbs=create;update,versions=v1,name=profiles.kubeflow.org
+
+// ProfileValidator rejects the Profiles and ProfileTemplates with plugins
+// which aren't registered, or whose spec is invalid.
+type ProfileValidator struct {
+	decoder *admission.Decoder
+}
+
+// Handle validates the plugins of the object. Updates which don't change
+// the plugins are always allowed, so that existing profiles with invalid
+// plugins can still be updated and deleted.
+func (v *ProfileValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var plugins, oldPlugins []profilev1.Plugin
+	switch req.Kind.Kind {
+	case "Profile":
+		profile, oldProfile := &profilev1.Profile{}, &profilev1.Profile{}
+		if err := v.decoder.Decode(req, profile); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if len(req.OldObject.Raw) > 0 {
+			if err := v.decoder.DecodeRaw(req.OldObject, oldProfile); err != nil {
+				return admission.Errored(http.StatusBadRequest, err)
+			}
+		}
+		plugins, oldPlugins = profile.Spec.Plugins, oldProfile.Spec.Plugins
+	case "ProfileTemplate":
+		template, oldTemplate := &profilev1.ProfileTemplate{}, &profilev1.ProfileTemplate{}
+		if err := v.decoder.Decode(req, template); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if len(req.OldObject.Raw) > 0 {
+			if err := v.decoder.DecodeRaw(req.OldObject, oldTemplate); err != nil {
+				return admission.Errored(http.StatusBadRequest, err)
+			}
+		}
+		plugins, oldPlugins = template.Spec.Plugins, oldTemplate.Spec.Plugins
+	default:
+		return admission.Allowed("")
+	}
+	if len(req.OldObject.Raw) > 0 && reflect.DeepEqual(plugins, oldPlugins) {
+		return admission.Allowed("plugins unchanged")
+	}
+	if errs := ValidatePlugins(plugins); len(errs) > 0 {
+		return admission.Denied(utilerrors.NewAggregate(errs).Error())
+	}
+	return admission.Allowed("")
+}
+
+// InjectDecoder is called by the webhook server to set the decoder.
+func (v *ProfileValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestProfileValidator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := profilev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := &ProfileValidator{}
+	if err := validator.InjectDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := `{"apiVersion": "kubeflow.org/v1", "kind": "Profile", "metadata": {"name": "alice"},
+		"spec": {"plugins": [{"kind": "AwsIamForServiceAccount", "spec": {"awsIamRole": "arn:aws:iam::34892524:role/s3-reader"}}]}}`
+	invalid := `{"apiVersion": "kubeflow.org/v1", "kind": "Profile", "metadata": {"name": "alice"},
+		"spec": {"plugins": [{"kind": "Unknown"}]}}`
+	invalidTemplate := `{"apiVersion": "kubeflow.org/v1", "kind": "ProfileTemplate", "metadata": {"name": "team"},
+		"spec": {"plugins": [{"kind": "WorkloadIdentity", "spec": {}}]}}`
+	tests := []struct {
+		name     string
+		kind     string
+		object   string
+		old      string
+		expected bool
+	}{
+		{"valid plugins", "Profile", valid, "", true},
+		{"unknown plugin", "Profile", invalid, "", false},
+		{"invalid plugin added", "Profile", invalid, valid, false},
+		{"invalid plugin unchanged", "Profile", invalid, invalid, true},
+		{"invalid template plugin", "ProfileTemplate", invalidTemplate, "", false},
+	}
+	for _, test := range tests {
+		req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Kind:   metav1.GroupVersionKind{Group: "kubeflow.org", Version: "v1", Kind: test.kind},
+			Object: runtime.RawExtension{Raw: []byte(test.object)},
+		}}
+		if test.old != "" {
+			req.OldObject = runtime.RawExtension{Raw: []byte(test.old)}
+		}
+		resp := validator.Handle(context.Background(), req)
+		if resp.Allowed != test.expected {
+			t.Errorf("%v: Expect:\n%v; Output:\n%v", test.name, test.expected, resp.Result)
+		}
+	}
+}
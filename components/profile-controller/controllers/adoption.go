@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Annotations of the adoption of existing namespaces
+const (
+	// Set to "true" on a Profile, same as spec.adoptExistingNamespace
+	ADOPTANNOTATION = "profiles.kubeflow.org/adopt-existing-namespace"
+	// Set by an administrator on a namespace, to the name of the profile allowed to adopt it
+	ADOPTIONAPPROVEDANNOTATION = "profiles.kubeflow.org/adoption-approved"
+	// Set by the controller on an adopted namespace, with its metadata before the adoption
+	PRIORSTATEANNOTATION = "profiles.kubeflow.org/prior-state"
+	// Set by the controller next to the owner annotation, to the kind of the owner
+	OWNERKINDANNOTATION = "profiles.kubeflow.org/owner-kind"
+)
+
+// namespacePriorState is the metadata of a namespace before its adoption,
+// restored when the namespace is released.
+type namespacePriorState struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// adoptionRequested returns whether "profileIns" asks to adopt its namespace
+// when it already exists.
+func adoptionRequested(profileIns *profilev1.Profile) bool {
+	return profileIns.Spec.AdoptExistingNamespace || profileIns.Annotations[ADOPTANNOTATION] == "true"
+}
+
+// adoptionBlocked returns the reason and message of the NamespaceReady
+// condition when "profileIns" can't take ownership of the existing namespace
+// "ns", or empty strings when it can adopt it.
+func adoptionBlocked(profileIns *profilev1.Profile, ns *corev1.Namespace) (string, string) {
+	if !adoptionRequested(profileIns) {
+		return "NamespaceOwnedByOther", fmt.Sprintf(
+			"namespace already exist, but not owned by profile creator %v", primaryOwner(profileIns).Name)
+	}
+	if ns.DeletionTimestamp != nil {
+		return "NamespaceOwnedByOther", fmt.Sprintf("namespace %v is being deleted", ns.Name)
+	}
+	if ns.Annotations[ADOPTIONAPPROVEDANNOTATION] != profileIns.Name {
+		return "NamespaceAdoptionPending", fmt.Sprintf(
+			"adoption of namespace %v is waiting for an administrator to annotate it with %v=%v",
+			ns.Name, ADOPTIONAPPROVEDANNOTATION, profileIns.Name)
+	}
+	return "", ""
+}
+
+// adoptNamespace records the labels and annotations of "ns" in its prior state
+// annotation, then makes the owner of "profileIns" its owner. The prior state
+// of a namespace adopted before is kept, so that it's restored to its
+// original state.
+func adoptNamespace(ns *corev1.Namespace, profileIns *profilev1.Profile) error {
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string)
+	}
+	delete(ns.Annotations, ADOPTIONAPPROVEDANNOTATION)
+	if _, ok := ns.Annotations[PRIORSTATEANNOTATION]; !ok {
+		prior := namespacePriorState{
+			Labels:      ns.Labels,
+			Annotations: ns.Annotations,
+		}
+		data, err := json.Marshal(prior)
+		if err != nil {
+			return err
+		}
+		ns.Annotations[PRIORSTATEANNOTATION] = string(data)
+	}
+	setOwnerAnnotations(ns, profileIns)
+	return nil
+}
+
+// setOwnerAnnotations records the primary owner of "profileIns" in the
+// annotations of "ns", and returns whether they changed.
+func setOwnerAnnotations(ns *corev1.Namespace, profileIns *profilev1.Profile) bool {
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string)
+	}
+	owner := primaryOwner(profileIns)
+	if ns.Annotations["owner"] == owner.Name && ns.Annotations[OWNERKINDANNOTATION] == owner.Kind {
+		return false
+	}
+	ns.Annotations["owner"] = owner.Name
+	ns.Annotations[OWNERKINDANNOTATION] = owner.Kind
+	return true
+}
+
+// ownsNamespace returns whether "ns" belongs to "profileIns": it's controlled
+// by it, or its owner annotations name the primary owner of the profile. The
+// other owners of the profile don't own a namespace the profile doesn't
+// control, as anyone could list them. Namespaces annotated before the owner
+// kind was recorded belong to users.
+func ownsNamespace(profileIns *profilev1.Profile, ns *corev1.Namespace) bool {
+	if metav1.IsControlledBy(ns, profileIns) {
+		return true
+	}
+	owner := primaryOwner(profileIns)
+	kind, ok := ns.Annotations[OWNERKINDANNOTATION]
+	if !ok {
+		kind = rbacv1.UserKind
+	}
+	return owner.Name != "" && ns.Annotations["owner"] == owner.Name && kind == owner.Kind
+}
+
+// releaseNamespace restores the labels and annotations "ns" had before its
+// adoption, and returns whether it was adopted.
+func releaseNamespace(ns *corev1.Namespace) (bool, error) {
+	data, ok := ns.Annotations[PRIORSTATEANNOTATION]
+	if !ok {
+		return false, nil
+	}
+	prior := namespacePriorState{}
+	if err := json.Unmarshal([]byte(data), &prior); err != nil {
+		return false, fmt.Errorf("invalid %v annotation: %v", PRIORSTATEANNOTATION, err)
+	}
+	ns.Labels = prior.Labels
+	ns.Annotations = prior.Annotations
+	return true, nil
+}
+
+// releaseAdoptedNamespace gives the namespace of "profileIns" back when it
+// was adopted, instead of letting it be garbage collected with the profile.
+func (r *ProfileReconciler) releaseAdoptedNamespace(profileIns *profilev1.Profile) error {
+	ctx := context.Background()
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profileIns.Name}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !ownsNamespace(profileIns, ns) {
+		return nil
+	}
+	released, err := releaseNamespace(ns)
+	if err != nil || !released {
+		return err
+	}
+	removeOwnerReference(ns, profileIns)
+	logger.Info("Releasing adopted Namespace: " + ns.Name)
+	if err := r.Update(ctx, ns); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(profileIns, corev1.EventTypeNormal, "NamespaceReleased",
+		"Namespace %v was restored to its state before its adoption", ns.Name)
+	return nil
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAdoptionBlocked(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name        string
+		adopt       bool
+		annotations map[string]string
+		ns          *corev1.Namespace
+		reason      string
+	}{
+		{
+			name:   "adoption not requested",
+			ns:     &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			reason: "NamespaceOwnedByOther",
+		},
+		{
+			name:   "adoption not approved",
+			adopt:  true,
+			ns:     &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			reason: "NamespaceAdoptionPending",
+		},
+		{
+			name:  "adoption approved for another profile",
+			adopt: true,
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team-a",
+				Annotations: map[string]string{ADOPTIONAPPROVEDANNOTATION: "team-b"},
+			}},
+			reason: "NamespaceAdoptionPending",
+		},
+		{
+			name:  "namespace being deleted",
+			adopt: true,
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:              "team-a",
+				Annotations:       map[string]string{ADOPTIONAPPROVEDANNOTATION: "team-a"},
+				DeletionTimestamp: &now,
+			}},
+			reason: "NamespaceOwnedByOther",
+		},
+		{
+			name:  "adoption approved",
+			adopt: true,
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team-a",
+				Annotations: map[string]string{ADOPTIONAPPROVEDANNOTATION: "team-a"},
+			}},
+		},
+		{
+			name:        "adoption requested with annotation",
+			annotations: map[string]string{ADOPTANNOTATION: "true"},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team-a",
+				Annotations: map[string]string{ADOPTIONAPPROVEDANNOTATION: "team-a"},
+			}},
+		},
+	}
+	for _, test := range tests {
+		profile := &profilev1.Profile{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: test.annotations},
+			Spec: profilev1.ProfileSpec{
+				Owner:                  rbacv1.Subject{Kind: "User", Name: "alice@example.com"},
+				AdoptExistingNamespace: test.adopt,
+			},
+		}
+		reason, _ := adoptionBlocked(profile, test.ns)
+		if reason != test.reason {
+			t.Errorf("%v: Expect:\n%q; Output:\n%q", test.name, test.reason, reason)
+		}
+	}
+}
+
+func TestAdoptAndReleaseNamespace(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Spec: profilev1.ProfileSpec{
+			Owner:                  rbacv1.Subject{Kind: "User", Name: "alice@example.com"},
+			AdoptExistingNamespace: true,
+		},
+	}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{"team": "a"},
+			Annotations: map[string]string{
+				"owner":                    "bob@example.com",
+				ADOPTIONAPPROVEDANNOTATION: "team-a",
+			},
+		},
+	}
+
+	if err := adoptNamespace(ns, profile); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Annotations["owner"] != "alice@example.com" {
+		t.Errorf("Expect:\n%v; Output:\n%v", "alice@example.com", ns.Annotations["owner"])
+	}
+	if _, ok := ns.Annotations[ADOPTIONAPPROVEDANNOTATION]; ok {
+		t.Errorf("Expect:\napproval annotation removed; Output:\n%v", ns.Annotations)
+	}
+	ns.Labels["katib.kubeflow.org/metrics-collector-injection"] = "enabled"
+
+	// Adopting again keeps the original prior state
+	if err := adoptNamespace(ns, profile); err != nil {
+		t.Fatal(err)
+	}
+	released, err := releaseNamespace(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !released {
+		t.Errorf("Expect:\nnamespace released; Output:\nnot released")
+	}
+	expectedLabels := map[string]string{"team": "a"}
+	if !reflect.DeepEqual(ns.Labels, expectedLabels) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedLabels, ns.Labels)
+	}
+	expectedAnnotations := map[string]string{"owner": "bob@example.com"}
+	if !reflect.DeepEqual(ns.Annotations, expectedAnnotations) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedAnnotations, ns.Annotations)
+	}
+
+	released, err = releaseNamespace(ns)
+	if err != nil || released {
+		t.Errorf("Expect:\nnamespace not adopted; Output:\n%v, %v", released, err)
+	}
+}
+
+func TestOwnsNamespace(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a", UID: "team-a-uid"},
+		Spec: profilev1.ProfileSpec{
+			Owners: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+				{Kind: rbacv1.UserKind, Name: "bob@example.com"},
+			},
+		},
+	}
+	isController := true
+	tests := []struct {
+		name     string
+		ns       metav1.ObjectMeta
+		expected bool
+	}{
+		{"primary owner", metav1.ObjectMeta{Annotations: map[string]string{"owner": "alice@example.com"}}, true},
+		{
+			"primary owner with kind",
+			metav1.ObjectMeta{Annotations: map[string]string{"owner": "alice@example.com", OWNERKINDANNOTATION: "User"}},
+			true,
+		},
+		{
+			"primary owner of another kind",
+			metav1.ObjectMeta{Annotations: map[string]string{"owner": "alice@example.com", OWNERKINDANNOTATION: "Group"}},
+			false,
+		},
+		// Anyone could list the owner of an uncontrolled namespace in their profile
+		{
+			"other owner on an uncontrolled namespace",
+			metav1.ObjectMeta{Annotations: map[string]string{"owner": "bob@example.com"}},
+			false,
+		},
+		{"not an owner", metav1.ObjectMeta{Annotations: map[string]string{"owner": "carol@example.com"}}, false},
+		{"no owner annotation", metav1.ObjectMeta{}, false},
+		{
+			"controlled by the profile",
+			metav1.ObjectMeta{
+				Annotations:     map[string]string{"owner": "carol@example.com"},
+				OwnerReferences: []metav1.OwnerReference{{UID: "team-a-uid", Controller: &isController}},
+			},
+			true,
+		},
+	}
+	for _, test := range tests {
+		if owns := ownsNamespace(profile, &corev1.Namespace{ObjectMeta: test.ns}); owns != test.expected {
+			t.Errorf("%v: Expect:\n%v; Output:\n%v", test.name, test.expected, owns)
+		}
+	}
+}
+
+func TestSetOwnerAnnotations(t *testing.T) {
+	profile := &profilev1.Profile{Spec: profilev1.ProfileSpec{
+		Owners: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "team-a"}},
+	}}
+	ns := &corev1.Namespace{}
+	if !setOwnerAnnotations(ns, profile) {
+		t.Errorf("Expect:\nannotations set; Output:\nunchanged")
+	}
+	expected := map[string]string{"owner": "team-a", OWNERKINDANNOTATION: "Group"}
+	if !reflect.DeepEqual(ns.Annotations, expected) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expected, ns.Annotations)
+	}
+	if setOwnerAnnotations(ns, profile) {
+		t.Errorf("Expect:\nunchanged; Output:\nannotations set")
+	}
+	if !ownsNamespace(profile, ns) {
+		t.Errorf("Expect:\nnamespace owned; Output:\nnot owned")
+	}
+}
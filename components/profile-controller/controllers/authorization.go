@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Names of the authorization backends
+const (
+	AUTHZBACKENDISTIO = "istio"
+	AUTHZBACKENDNONE  = "none"
+)
+
+// AuthorizationBackend grants the owners and contributors of a Profile access
+// to the services of its namespace. Access through the Kubernetes API is
+// always granted with RBAC by the controller.
+type AuthorizationBackend interface {
+	// Labels set on the namespace of a Profile when it's created
+	NamespaceLabels() map[string]string
+	// Called when profile CR is created / updated, to grant access to the owners
+	UpdateOwners(*ProfileReconciler, *profilev1.Profile) error
+	// Called when profile CR is created / updated, to grant access to the
+	// contributors and revoke it from removed contributors
+	UpdateContributors(*ProfileReconciler, *profilev1.Profile) error
+	// The types of the objects created by the backend, watched by the controller
+	OwnedTypes() []runtime.Object
+}
+
+// authorizationBackends maps the names of the backends to their constructor.
+var authorizationBackends = map[string]func() AuthorizationBackend{
+	AUTHZBACKENDISTIO: func() AuthorizationBackend { return &IstioAuthorization{} },
+	AUTHZBACKENDNONE:  func() AuthorizationBackend { return &NoAuthorization{} },
+}
+
+// NewAuthorizationBackend returns the authorization backend named "name".
+func NewAuthorizationBackend(name string) (AuthorizationBackend, error) {
+	newBackend, ok := authorizationBackends[name]
+	if !ok {
+		names := []string{}
+		for n := range authorizationBackends {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown authorization backend %q, must be one of %v", name, names)
+	}
+	return newBackend(), nil
+}
+
+// IstioAuthorization grants access with Istio AuthorizationPolicies, and
+// injects the Istio sidecar in all the pods of the namespace.
+type IstioAuthorization struct{}
+
+func (*IstioAuthorization) NamespaceLabels() map[string]string {
+	return map[string]string{istioInjectionLabel: "enabled"}
+}
+
+func (*IstioAuthorization) UpdateOwners(r *ProfileReconciler, profileIns *profilev1.Profile) error {
+	return r.updateIstioAuthorizationPolicy(profileIns)
+}
+
+func (*IstioAuthorization) UpdateContributors(r *ProfileReconciler, profileIns *profilev1.Profile) error {
+	return r.updateContributorAuthorizationPolicies(profileIns)
+}
+
+func (*IstioAuthorization) OwnedTypes() []runtime.Object {
+	return []runtime.Object{&istioSecurityClient.AuthorizationPolicy{}}
+}
+
+// NoAuthorization only relies on Kubernetes RBAC, for clusters without a
+// service mesh. Services of the namespaces must do their own authorization.
+type NoAuthorization struct{}
+
+func (*NoAuthorization) NamespaceLabels() map[string]string {
+	return map[string]string{}
+}
+
+func (*NoAuthorization) UpdateOwners(*ProfileReconciler, *profilev1.Profile) error {
+	return nil
+}
+
+func (*NoAuthorization) UpdateContributors(*ProfileReconciler, *profilev1.Profile) error {
+	return nil
+}
+
+func (*NoAuthorization) OwnedTypes() []runtime.Object {
+	return nil
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewAuthorizationBackend(t *testing.T) {
+	istio, err := NewAuthorizationBackend(AUTHZBACKENDISTIO)
+	if err != nil {
+		t.Fatalf("Expect:\nnil; Output:\n%v", err)
+	}
+	expectedLabels := map[string]string{istioInjectionLabel: "enabled"}
+	if !reflect.DeepEqual(istio.NamespaceLabels(), expectedLabels) || len(istio.OwnedTypes()) != 1 {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedLabels, istio.NamespaceLabels())
+	}
+
+	none, err := NewAuthorizationBackend(AUTHZBACKENDNONE)
+	if err != nil {
+		t.Fatalf("Expect:\nnil; Output:\n%v", err)
+	}
+	if len(none.NamespaceLabels()) != 0 || len(none.OwnedTypes()) != 0 {
+		t.Errorf("Expect:\nno labels and owned types; Output:\n%v, %v", none.NamespaceLabels(), none.OwnedTypes())
+	}
+	if err := none.UpdateOwners(nil, nil); err != nil {
+		t.Errorf("Expect:\nnil; Output:\n%v", err)
+	}
+
+	if _, err := NewAuthorizationBackend("linkerd"); err == nil {
+		t.Errorf("Expect:\nerror; Output:\nnil")
+	}
+}
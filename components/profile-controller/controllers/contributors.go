@@ -0,0 +1,230 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	istioSecurity "istio.io/api/security/v1beta1"
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Label of the RoleBindings and AuthorizationPolicies generated for
+// spec.contributors, used to prune the ones of removed contributors.
+const CONTRIBUTORBINDINGLABEL = "profiles.kubeflow.org/contributor-binding"
+
+// validateAccess checks that owners and contributors are users or groups,
+// and that contributors have a role of the catalog. Contributors are listed
+// once per role, and their bindings mustn't have the name of the binding of
+// another contributor.
+func validateAccess(profileIns *profilev1.Profile, roles *RoleCatalog) error {
+	owners := profileOwners(profileIns)
+	if len(owners) == 0 {
+		return fmt.Errorf("profile %v has no owner", profileIns.Name)
+	}
+	for _, owner := range owners {
+		if owner.Kind != rbacv1.UserKind && owner.Kind != rbacv1.GroupKind {
+			return fmt.Errorf("owner %v is of kind %v, only %v and %v are supported", owner.Name, owner.Kind,
+				rbacv1.UserKind, rbacv1.GroupKind)
+		}
+	}
+	bindings := map[string]profilev1.Contributor{}
+	for _, contributor := range profileIns.Spec.Contributors {
+		if contributor.Subject.Kind != rbacv1.UserKind && contributor.Subject.Kind != rbacv1.GroupKind {
+			return fmt.Errorf("contributor %v is of kind %v, only %v and %v are supported",
+				contributor.Subject.Name, contributor.Subject.Kind, rbacv1.UserKind, rbacv1.GroupKind)
+		}
+		if _, ok := roles.ClusterRole(contributor.Role); !ok {
+			return fmt.Errorf("contributor %v has unknown role %v, the roles are %v", contributor.Subject.Name,
+				contributor.Role, strings.Join(roles.Names(), ", "))
+		}
+		name := contributorBindingName(contributor)
+		if other, ok := bindings[name]; ok {
+			if other.Subject.Kind == contributor.Subject.Kind && other.Subject.Name == contributor.Subject.Name {
+				return fmt.Errorf("contributor %v is listed twice with role %v", contributor.Subject.Name,
+					contributor.Role)
+			}
+			return fmt.Errorf("contributors %v and %v with role %v would share the binding %v",
+				other.Subject.Name, contributor.Subject.Name, contributor.Role, name)
+		}
+		bindings[name] = contributor
+	}
+	return nil
+}
+
+// profileOwners returns the owners of "profileIns": the deprecated owner
+// field first, then the ones listed in owners, without duplicates.
+func profileOwners(profileIns *profilev1.Profile) []rbacv1.Subject {
+	owners := []rbacv1.Subject{}
+	seen := map[string]bool{}
+	for _, owner := range append([]rbacv1.Subject{profileIns.Spec.Owner}, profileIns.Spec.Owners...) {
+		key := owner.Kind + "/" + owner.Name
+		if owner.Name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// primaryOwner returns the first owner of "profileIns", recorded in the owner
+// annotation of its namespace and bound by the namespaceAdmin RoleBinding.
+func primaryOwner(profileIns *profilev1.Profile) rbacv1.Subject {
+	if owners := profileOwners(profileIns); len(owners) > 0 {
+		return owners[0]
+	}
+	return rbacv1.Subject{}
+}
+
+// contributorBindingName returns the name of the RoleBinding and
+// AuthorizationPolicy of a contributor, e.g. user-alice-example-com-clusterrole-edit.
+// kfam used the same names for the bindings it created, so those are adopted.
+func contributorBindingName(contributor profilev1.Contributor) string {
+	// Only keep lower case letters and numbers, replace other with -
+	reg := regexp.MustCompile("[^a-z0-9]+")
+	return reg.ReplaceAllString(strings.ToLower(
+		contributor.Subject.Kind+"-"+contributor.Subject.Name+"-clusterrole-"+contributor.Role), "-")
+}
+
+// getContributorAuthorizationPolicy returns the policy allowing the
+// contributor to access the services of the namespace, or false for groups
+// when the controller doesn't know the groups header.
+func (r *ProfileReconciler) getContributorAuthorizationPolicy(
+	contributor profilev1.Contributor) (istioSecurity.AuthorizationPolicy, bool) {
+	condition := &istioSecurity.Condition{
+		Key:    fmt.Sprintf("request.headers[%v]", r.UserIdHeader),
+		Values: []string{r.UserIdPrefix + contributor.Subject.Name},
+	}
+	if contributor.Subject.Kind == rbacv1.GroupKind {
+		if r.GroupsHeader == "" {
+			return istioSecurity.AuthorizationPolicy{}, false
+		}
+		condition = &istioSecurity.Condition{
+			Key:    fmt.Sprintf("request.headers[%v]", r.GroupsHeader),
+			Values: groupHeaderValues(contributor.Subject.Name),
+		}
+	}
+	return istioSecurity.AuthorizationPolicy{
+		Rules: []*istioSecurity.Rule{
+			{
+				When: []*istioSecurity.Condition{condition},
+			},
+		},
+	}, true
+}
+
+// updateContributors create or update the RoleBinding of every contributor
+// of "profileIns", and delete the ones of removed contributors. Their access
+// to the services of the namespace is granted by the authorization backend.
+func (r *ProfileReconciler) updateContributors(profileIns *profilev1.Profile) error {
+	desired := map[string]bool{}
+	for _, contributor := range profileIns.Spec.Contributors {
+		name := contributorBindingName(contributor)
+		clusterRole, _ := r.roles().ClusterRole(contributor.Role)
+		roleBinding := &rbacv1.RoleBinding{
+			ObjectMeta: contributorObjectMeta(profileIns, contributor),
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     clusterRole,
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					APIGroup: rbacv1.GroupName,
+					Kind:     contributor.Subject.Kind,
+					Name:     contributor.Subject.Name,
+				},
+			},
+		}
+		desired[name] = true
+		if err := r.updateRoleBinding(profileIns, roleBinding); err != nil {
+			return err
+		}
+	}
+	if err := r.pruneRoleBindings(profileIns, CONTRIBUTORBINDINGLABEL, desired); err != nil {
+		return err
+	}
+	return r.Authorization.UpdateContributors(r, profileIns)
+}
+
+// contributorObjectMeta returns the metadata of the RoleBinding and
+// AuthorizationPolicy of a contributor.
+func contributorObjectMeta(profileIns *profilev1.Profile, contributor profilev1.Contributor) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Annotations: map[string]string{USER: contributor.Subject.Name, ROLE: contributor.Role},
+		Labels:      map[string]string{CONTRIBUTORBINDINGLABEL: "true"},
+		Name:        contributorBindingName(contributor),
+		Namespace:   profileIns.Name,
+	}
+}
+
+// updateContributorAuthorizationPolicies create or update the Istio
+// AuthorizationPolicy of every contributor of "profileIns", and delete the
+// ones of removed contributors.
+func (r *ProfileReconciler) updateContributorAuthorizationPolicies(profileIns *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	desired := map[string]bool{}
+	for _, contributor := range profileIns.Spec.Contributors {
+		spec, ok := r.getContributorAuthorizationPolicy(contributor)
+		if !ok {
+			logger.Info("Not creating AuthorizationPolicy for group contributor, no groups header is set",
+				"group", contributor.Subject.Name)
+			continue
+		}
+		istioAuth := &istioSecurityClient.AuthorizationPolicy{
+			ObjectMeta: contributorObjectMeta(profileIns, contributor),
+			Spec:       spec,
+		}
+		desired[istioAuth.Name] = true
+		if err := r.updateAuthorizationPolicy(profileIns, istioAuth); err != nil {
+			return err
+		}
+	}
+	return r.pruneAuthorizationPolicies(profileIns, CONTRIBUTORBINDINGLABEL, desired)
+}
+
+// pruneAuthorizationPolicies deletes the AuthorizationPolicies with label
+// "label" owned by "profileIns" whose name isn't in "desired".
+func (r *ProfileReconciler) pruneAuthorizationPolicies(profileIns *profilev1.Profile, label string,
+	desired map[string]bool) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	found := &istioSecurityClient.AuthorizationPolicyList{}
+	if err := r.List(context.TODO(), found, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{label: "true"}); err != nil {
+		return err
+	}
+	for i := range found.Items {
+		policy := &found.Items[i]
+		if desired[policy.Name] || !metav1.IsControlledBy(policy, profileIns) {
+			continue
+		}
+		logger.Info("Deleting AuthorizationPolicy", "namespace", policy.Namespace, "name", policy.Name)
+		if err := r.Delete(context.TODO(), policy); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// deletionPolicy returns the deletion policy of "profileIns", Delete when
+// it's not set.
+func deletionPolicy(profileIns *profilev1.Profile) profilev1.DeletionPolicy {
+	if profileIns.Spec.DeletionPolicy == "" {
+		return profilev1.DeletionPolicyDelete
+	}
+	return profileIns.Spec.DeletionPolicy
+}
+
+// managedTypes returns the types of the objects the controller creates in
+// the namespaces of the profiles.
+func (r *ProfileReconciler) managedTypes() []runtime.Object {
+	managed := []runtime.Object{
+		&corev1.ServiceAccount{},
+		&rbacv1.RoleBinding{},
+		&corev1.LimitRange{},
+		&corev1.ResourceQuota{},
+		&networkingv1.NetworkPolicy{},
+	}
+	return append(managed, r.Authorization.OwnedTypes()...)
+}
+
+// removeOwnerReference removes the references to "owner" from the owner
+// references of "obj", and returns whether there were some.
+func removeOwnerReference(obj metav1.Object, owner metav1.Object) bool {
+	references := []metav1.OwnerReference{}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != owner.GetUID() {
+			references = append(references, ref)
+		}
+	}
+	if len(references) == len(obj.GetOwnerReferences()) {
+		return false
+	}
+	obj.SetOwnerReferences(references)
+	return true
+}
+
+// listManagedObjects returns the objects of the namespace of "profileIns"
+// which are controlled by it.
+func (r *ProfileReconciler) listManagedObjects(ctx context.Context,
+	profileIns *profilev1.Profile) ([]*unstructured.Unstructured, error) {
+	gvks := []schema.GroupVersionKind{podDefaultGVK}
+	for _, obj := range r.managedTypes() {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		gvks = append(gvks, gvk)
+	}
+	objects := []*unstructured.Unstructured{}
+	for _, gvk := range gvks {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := r.List(ctx, list, client.InNamespace(profileIns.Name)); err != nil {
+			// Optional types, e.g. PodDefaults, may not be installed
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, err
+		}
+		for i := range list.Items {
+			if metav1.IsControlledBy(&list.Items[i], profileIns) {
+				objects = append(objects, &list.Items[i])
+			}
+		}
+	}
+	return objects, nil
+}
+
+// finalize revokes the plugins of "instance", which is being deleted, and
+// applies its deletion policy before removing its finalizer. Nothing else is
+// validated or reconciled, so that an invalid spec or a terminating namespace
+// can't block the deletion. With the FORCEFINALIZEANNOTATION annotation, the
+// steps which fail are skipped and the finalizer is removed anyway.
+func (r *ProfileReconciler) finalize(ctx context.Context, instance *profilev1.Profile) (ctrl.Result, error) {
+	logger := r.Log.WithValues("profile", instance.Name)
+	if !containsString(instance.ObjectMeta.Finalizers, PROFILEFINALIZER) {
+		return ctrl.Result{}, nil
+	}
+	status := &profileStatus{}
+	// skip returns whether the failed step can be skipped
+	skip := func(step string, err error) bool {
+		logger.Error(err, "error "+step, "namespace", instance.Name)
+		IncRequestErrorCounter("error "+step, SEVERITY_MAJOR)
+		if !forceFinalize(instance) {
+			return false
+		}
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, "FinalizationStepSkipped",
+			"Skipped %v, as %v is set: %v", step, FORCEFINALIZEANNOTATION, err)
+		return true
+	}
+
+	// The default plugins of the template are revoked too, a missing template
+	// is ignored
+	template, err := r.getTemplate(ctx, instance)
+	if err != nil && !errors.IsNotFound(err) {
+		if !skip("reading profile template", err) {
+			return r.failAndReturn(ctx, instance, status, profilev1.TemplateReady, "TemplateReadFailed", err.Error(), err)
+		}
+		template = nil
+	}
+	profile := mergeTemplate(instance, template)
+	// The plugins are revoked, unless the namespace is orphaned
+	if deletionPolicy(instance) != profilev1.DeletionPolicyOrphan {
+		if err := r.finalizePlugins(instance, profile, status); err != nil {
+			return r.failAndReturn(ctx, instance, status, profilev1.PluginsReady, "PluginRevocationFailed",
+				err.Error(), err)
+		}
+	}
+	if err := r.writeStatus(ctx, instance, status); err != nil && !skip("updating status", err) {
+		return ctrl.Result{}, err
+	}
+	// Keep or release the namespace when the deletion policy asks for it,
+	// instead of letting it be garbage collected
+	if err := r.applyDeletionPolicy(instance); err != nil && !skip("applying deletion policy", err) {
+		return ctrl.Result{}, err
+	}
+
+	instance.ObjectMeta.Finalizers = removeString(instance.ObjectMeta.Finalizers, PROFILEFINALIZER)
+	if err := r.Update(ctx, instance); err != nil {
+		logger.Error(err, "error removing finalizer", "namespace", instance.Name)
+		IncRequestErrorCounter("error removing finalizer", SEVERITY_MAJOR)
+		return ctrl.Result{}, err
+	}
+	IncRequestCounter("profile finalization")
+	return ctrl.Result{}, nil
+}
+
+// applyDeletionPolicy prepares the namespace of "profileIns" for the deletion
+// of the profile, before its finalizer is removed. With Delete the namespace
+// is garbage collected, unless it was adopted, then it's released. With
+// Retain it's released or kept without the owner reference to the profile,
+// and the objects managed by the controller are deleted. With Orphan
+// the owner references to the profile are removed from the namespace and
+// the managed objects.
+func (r *ProfileReconciler) applyDeletionPolicy(profileIns *profilev1.Profile) error {
+	ctx := context.Background()
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	policy := deletionPolicy(profileIns)
+	if policy != profilev1.DeletionPolicyOrphan {
+		if err := r.releaseAdoptedNamespace(profileIns); err != nil {
+			return err
+		}
+	}
+	if policy == profilev1.DeletionPolicyDelete {
+		return nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profileIns.Name}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if removeOwnerReference(ns, profileIns) {
+		logger.Info("Keeping Namespace: "+ns.Name, "deletionPolicy", policy)
+		if err := r.Update(ctx, ns); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(profileIns, corev1.EventTypeNormal, "NamespaceRetained",
+			"Namespace %v is kept after the deletion of the profile, deletion policy %v", ns.Name, policy)
+	}
+
+	objects, err := r.listManagedObjects(ctx, profileIns)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if policy == profilev1.DeletionPolicyRetain {
+			logger.Info("Deleting "+obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName())
+			if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		removeOwnerReference(obj, profileIns)
+		logger.Info("Orphaning "+obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName())
+		if err := r.Update(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
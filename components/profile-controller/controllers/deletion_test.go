@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDeletionPolicy(t *testing.T) {
+	profile := &profilev1.Profile{}
+	if policy := deletionPolicy(profile); policy != profilev1.DeletionPolicyDelete {
+		t.Errorf("Expect:\n%v; Output:\n%v", profilev1.DeletionPolicyDelete, policy)
+	}
+	profile.Spec.DeletionPolicy = profilev1.DeletionPolicyRetain
+	if policy := deletionPolicy(profile); policy != profilev1.DeletionPolicyRetain {
+		t.Errorf("Expect:\n%v; Output:\n%v", profilev1.DeletionPolicyRetain, policy)
+	}
+}
+
+func TestRemoveOwnerReference(t *testing.T) {
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "alice", UID: "profile-uid"}}
+	other := metav1.OwnerReference{Kind: "Deployment", Name: "other", UID: "other-uid"}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "alice",
+			OwnerReferences: []metav1.OwnerReference{
+				other,
+				{Kind: "Profile", Name: "alice", UID: "profile-uid"},
+			},
+		},
+	}
+	if !removeOwnerReference(ns, profile) {
+		t.Errorf("Expect:\nowner reference removed; Output:\nnot removed")
+	}
+	expected := []metav1.OwnerReference{other}
+	if !reflect.DeepEqual(ns.OwnerReferences, expected) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expected, ns.OwnerReferences)
+	}
+	if removeOwnerReference(ns, profile) {
+		t.Errorf("Expect:\nno owner reference; Output:\nremoved")
+	}
+}
+
+// newDeletionTest returns a reconciler with a fake client holding the
+// namespace of profile "alice", a ServiceAccount and a RoleBinding controlled
+// by the profile, and a RoleBinding created by a user.
+func newDeletionTest(t *testing.T, policy profilev1.DeletionPolicy,
+	ns *corev1.Namespace) (*ProfileReconciler, *profilev1.Profile) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := profilev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	// PodDefaults are installed by the admission-webhook
+	scheme.AddKnownTypeWithName(podDefaultGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(podDefaultGVK.GroupVersion().WithKind("PodDefaultList"), &unstructured.UnstructuredList{})
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", UID: "profile-uid"},
+		Spec: profilev1.ProfileSpec{
+			Owner:          rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+			DeletionPolicy: policy,
+		},
+	}
+	isController := true
+	controlled := metav1.ObjectMeta{
+		Namespace: "alice",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: profilev1.GroupVersion.String(), Kind: "Profile", Name: "alice", UID: "profile-uid",
+			Controller: &isController,
+		}},
+	}
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: *controlled.DeepCopy()}
+	serviceAccount.Name = DEFAULT_EDITOR
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: *controlled.DeepCopy()}
+	roleBinding.Name = "namespaceAdmin"
+	userRoleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "user-binding", Namespace: "alice"}}
+	r := &ProfileReconciler{
+		Client:        fake.NewFakeClientWithScheme(scheme, ns, serviceAccount, roleBinding, userRoleBinding),
+		Scheme:        scheme,
+		Log:           ctrl.Log,
+		Recorder:      record.NewFakeRecorder(10),
+		Authorization: &NoAuthorization{},
+	}
+	return r, profile
+}
+
+// profileNamespace returns the namespace of profile "alice", controlled by it.
+func profileNamespace() *corev1.Namespace {
+	isController := true
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "alice",
+		Annotations: map[string]string{"owner": "alice@example.com"},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: profilev1.GroupVersion.String(), Kind: "Profile", Name: "alice", UID: "profile-uid",
+			Controller: &isController,
+		}},
+	}}
+}
+
+// exists returns whether the object "name" of the namespace of profile
+// "alice" exists.
+func exists(t *testing.T, r *ProfileReconciler, name string, obj runtime.Object) bool {
+	err := r.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "alice"}, obj)
+	if err != nil && !errors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestApplyDeletionPolicyRetain(t *testing.T) {
+	r, profile := newDeletionTest(t, profilev1.DeletionPolicyRetain, profileNamespace())
+	if err := r.applyDeletionPolicy(profile); err != nil {
+		t.Fatal(err)
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: "alice"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if len(ns.OwnerReferences) != 0 {
+		t.Errorf("Expect:\nno owner reference; Output:\n%v", ns.OwnerReferences)
+	}
+	if exists(t, r, DEFAULT_EDITOR, &corev1.ServiceAccount{}) || exists(t, r, "namespaceAdmin", &rbacv1.RoleBinding{}) {
+		t.Errorf("Expect:\nmanaged objects deleted; Output:\nkept")
+	}
+	if !exists(t, r, "user-binding", &rbacv1.RoleBinding{}) {
+		t.Errorf("Expect:\nuser RoleBinding kept; Output:\ndeleted")
+	}
+}
+
+func TestApplyDeletionPolicyOrphan(t *testing.T) {
+	r, profile := newDeletionTest(t, profilev1.DeletionPolicyOrphan, profileNamespace())
+	if err := r.applyDeletionPolicy(profile); err != nil {
+		t.Fatal(err)
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: "alice"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if len(ns.OwnerReferences) != 0 {
+		t.Errorf("Expect:\nno owner reference; Output:\n%v", ns.OwnerReferences)
+	}
+	serviceAccount, roleBinding := &corev1.ServiceAccount{}, &rbacv1.RoleBinding{}
+	if !exists(t, r, DEFAULT_EDITOR, serviceAccount) || !exists(t, r, "namespaceAdmin", roleBinding) {
+		t.Fatalf("Expect:\nmanaged objects kept; Output:\ndeleted")
+	}
+	if len(serviceAccount.OwnerReferences) != 0 || len(roleBinding.OwnerReferences) != 0 {
+		t.Errorf("Expect:\nno owner reference; Output:\n%v, %v",
+			serviceAccount.OwnerReferences, roleBinding.OwnerReferences)
+	}
+}
+
+func TestApplyDeletionPolicyDeleteAdopted(t *testing.T) {
+	adopted := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "alice",
+		Labels: map[string]string{"team": "a", "app.kubernetes.io/part-of": "kubeflow-profile"},
+		Annotations: map[string]string{
+			"owner":              "alice@example.com",
+			PRIORSTATEANNOTATION: `{"labels":{"team":"a"},"annotations":{"owner":"bob@example.com"}}`,
+		},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: profilev1.GroupVersion.String(), Kind: "Profile", Name: "alice", UID: "profile-uid",
+		}},
+	}}
+	r, profile := newDeletionTest(t, profilev1.DeletionPolicyDelete, adopted)
+	if err := r.applyDeletionPolicy(profile); err != nil {
+		t.Fatal(err)
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: "alice"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	expected := metav1.ObjectMeta{
+		Labels:      map[string]string{"team": "a"},
+		Annotations: map[string]string{"owner": "bob@example.com"},
+	}
+	if !reflect.DeepEqual(ns.Labels, expected.Labels) || !reflect.DeepEqual(ns.Annotations, expected.Annotations) ||
+		len(ns.OwnerReferences) != 0 {
+		t.Errorf("Expect:\n%v; Output:\n%v", expected, ns.ObjectMeta)
+	}
+	// The managed objects are garbage collected with the profile
+	if !exists(t, r, DEFAULT_EDITOR, &corev1.ServiceAccount{}) {
+		t.Errorf("Expect:\nmanaged objects kept; Output:\ndeleted")
+	}
+}
+
+// failingDeleteClient fails to delete any object.
+type failingDeleteClient struct {
+	client.Client
+}
+
+func (c failingDeleteClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	return errors.NewServiceUnavailable("unavailable")
+}
+
+func TestFinalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		owner      rbacv1.Subject
+		policy     profilev1.DeletionPolicy
+		failDelete bool
+		force      bool
+		finalized  bool
+	}{
+		{
+			name:      "invalid profile",
+			policy:    profilev1.DeletionPolicyRetain,
+			finalized: true,
+		},
+		{
+			name:       "failing deletion policy",
+			owner:      rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+			policy:     profilev1.DeletionPolicyRetain,
+			failDelete: true,
+		},
+		{
+			name:       "forced failing deletion policy",
+			owner:      rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+			policy:     profilev1.DeletionPolicyRetain,
+			failDelete: true,
+			force:      true,
+			finalized:  true,
+		},
+	}
+	for _, test := range tests {
+		r, profile := newDeletionTest(t, test.policy, profileNamespace())
+		now := metav1.Now()
+		profile.DeletionTimestamp = &now
+		profile.Finalizers = []string{PROFILEFINALIZER}
+		profile.Spec.Owner = test.owner
+		if test.force {
+			profile.Annotations = map[string]string{FORCEFINALIZEANNOTATION: "true"}
+		}
+		if err := r.Create(context.Background(), profile); err != nil {
+			t.Fatal(err)
+		}
+		if test.failDelete {
+			r.Client = failingDeleteClient{r.Client}
+		}
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "alice"}})
+		if test.finalized != (err == nil) {
+			t.Errorf("%v: Expect:\nerror %v; Output:\n%v", test.name, !test.finalized, err)
+		}
+
+		finalized := &profilev1.Profile{}
+		if err := r.Get(context.Background(), types.NamespacedName{Name: "alice"}, finalized); err != nil {
+			t.Fatal(err)
+		}
+		if test.finalized == containsString(finalized.Finalizers, PROFILEFINALIZER) {
+			t.Errorf("%v: Expect:\nfinalized %v; Output:\n%v", test.name, test.finalized, finalized.Finalizers)
+		}
+	}
+}
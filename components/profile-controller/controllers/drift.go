@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Reason of the Events emitted when manual changes to the objects managed by
+// the controller are reverted.
+const DRIFTCORRECTED = "DriftCorrected"
+
+// recordDrift emits an Event on the profile listing the fields of the managed
+// object "obj" of kind "kind" which are reverted. Differences found while the
+// profile itself changed come from the new spec rather than from manual
+// changes, and aren't reported.
+func (r *ProfileReconciler) recordDrift(profileIns *profilev1.Profile, kind string, obj metav1.Object,
+	fields ...string) {
+	if len(fields) == 0 || profileIns.Generation != profileIns.Status.ObservedGeneration {
+		return
+	}
+	name := obj.GetName()
+	if obj.GetNamespace() != "" {
+		name = obj.GetNamespace() + "/" + name
+	}
+	r.Log.Info("Reverting manual changes", "profile", profileIns.Name, "kind", kind, "name", name,
+		"fields", fields)
+	IncRequestCounter("drift corrected")
+	r.Recorder.Eventf(profileIns, corev1.EventTypeWarning, DRIFTCORRECTED,
+		"Reverted manual changes to %v %v: %v", kind, name, strings.Join(fields, ", "))
+}
+
+// jsonEqual returns whether "a" and "b" have the same JSON representation,
+// for the types whose in-memory representation holds more than their value,
+// like protobuf messages.
+func jsonEqual(a, b interface{}) bool {
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(rawA) == string(rawB)
+}
+
+// enforceLabels sets the labels on "obj", and returns the fields of the ones
+// which had another value, sorted.
+func enforceLabels(obj metav1.Object, labels map[string]string) []string {
+	drifted := []string{}
+	merged := obj.GetLabels()
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	for k, v := range labels {
+		if merged[k] != v {
+			merged[k] = v
+			drifted = append(drifted, "labels."+k)
+		}
+	}
+	sort.Strings(drifted)
+	obj.SetLabels(merged)
+	return drifted
+}
+
+// profileForNamespace returns the request of the profile of a namespace, so
+// that changes to namespaces are reverted. Owns() doesn't cover adopted
+// namespaces, which aren't controlled by their profile.
+func profileForNamespace(a handler.MapObject) []reconcile.Request {
+	ns := a.Meta
+	if ref := metav1.GetControllerOf(ns); ref != nil && ref.Kind == "Profile" ||
+		ns.GetAnnotations()[ADOPTIONAPPROVEDANNOTATION] == ns.GetName() {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: ns.GetName()}}}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+func TestEnforceLabels(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "alice",
+		Labels: map[string]string{"istio-injection": "disabled", "team": "ml"},
+	}}
+	drifted := enforceLabels(ns, map[string]string{"istio-injection": "enabled"})
+	if !reflect.DeepEqual(drifted, []string{"labels.istio-injection"}) {
+		t.Errorf("Expect:\n%v; Output:\n%v", []string{"labels.istio-injection"}, drifted)
+	}
+	expected := map[string]string{"istio-injection": "enabled", "team": "ml"}
+	if !reflect.DeepEqual(ns.Labels, expected) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expected, ns.Labels)
+	}
+	if drifted := enforceLabels(ns, map[string]string{"istio-injection": "enabled"}); len(drifted) != 0 {
+		t.Errorf("Expect:\nno drift; Output:\n%v", drifted)
+	}
+}
+
+func TestProfileForNamespace(t *testing.T) {
+	isController := true
+	tests := []struct {
+		ns       *corev1.Namespace
+		expected int
+	}{
+		{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}}, 0},
+		{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:            "alice",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Profile", Name: "alice", Controller: &isController}},
+		}}, 1},
+		{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "team-a",
+			Annotations: map[string]string{ADOPTIONAPPROVEDANNOTATION: "team-a"},
+		}}, 1},
+	}
+	for _, test := range tests {
+		requests := profileForNamespace(handler.MapObject{Meta: test.ns, Object: test.ns})
+		if len(requests) != test.expected {
+			t.Errorf("Expect:\n%v requests for %v; Output:\n%v", test.expected, test.ns.Name, requests)
+		} else if len(requests) == 1 && requests[0].Name != test.ns.Name {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.ns.Name, requests[0].Name)
+		}
+	}
+}
+
+func TestRevertLimitRangeDrift(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := profilev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	r := &ProfileReconciler{Client: fake.NewFakeClientWithScheme(scheme), Scheme: scheme, Log: ctrl.Log, Recorder: recorder}
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "alice", UID: "profile-uid", Generation: 1}}
+	spec := &corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+		Type:           corev1.LimitTypeContainer,
+		DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+	}}}
+	ctx := context.Background()
+
+	// The new spec of the profile isn't reported as drift
+	if err := r.updateLimitRange(profile, spec); err != nil {
+		t.Fatal(err)
+	}
+	found := &corev1.LimitRange{}
+	if err := r.Get(ctx, types.NamespacedName{Name: KFLIMITRANGE, Namespace: "alice"}, found); err != nil {
+		t.Fatal(err)
+	}
+	found.Spec.Limits[0].DefaultRequest[corev1.ResourceCPU] = resource.MustParse("4")
+	if err := r.Update(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.updateLimitRange(profile, spec); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expect:\nno event while the profile changes; Output:\n%v events", len(recorder.Events))
+	}
+
+	// Once the profile is reconciled, manual changes are reverted with an Event
+	profile.Status.ObservedGeneration = 1
+	if err := r.Get(ctx, types.NamespacedName{Name: KFLIMITRANGE, Namespace: "alice"}, found); err != nil {
+		t.Fatal(err)
+	}
+	found.Spec.Limits[0].DefaultRequest[corev1.ResourceCPU] = resource.MustParse("4")
+	if err := r.Update(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.updateLimitRange(profile, spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: KFLIMITRANGE, Namespace: "alice"}, found); err != nil {
+		t.Fatal(err)
+	}
+	cpu := found.Spec.Limits[0].DefaultRequest[corev1.ResourceCPU]
+	if cpu.String() != "100m" {
+		t.Errorf("Expect:\n100m; Output:\n%v", cpu.String())
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, DRIFTCORRECTED) || !strings.Contains(event, "LimitRange alice/"+KFLIMITRANGE) {
+			t.Errorf("Expect:\n%v event; Output:\n%v", DRIFTCORRECTED, event)
+		}
+	default:
+		t.Errorf("Expect:\n%v event; Output:\nnone", DRIFTCORRECTED)
+	}
+}
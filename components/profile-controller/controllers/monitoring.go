@@ -2,10 +2,13 @@ package controllers
 
 import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sync"
 	"time"
 
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 )
 
 const PROFILE = "profile_controller"
@@ -20,11 +23,31 @@ const SEVERITY = "severity"
 const SEVERITY_MINOR = "minor"
 const SEVERITY_MAJOR = "major"
 const SEVERITY_CRITICAL = "critical"
-const MAX_TAG_LEN = 30
+const PROFILELABEL = "profile"
+const RESOURCE = "resource"
+const RESULT = "result"
+const OPERATION = "operation"
+const STATE = "state"
+
+// Values of the RESULT label
+const RESULT_SUCCESS = "success"
+const RESULT_ERROR = "error"
+
+// Values of the OPERATION label of the plugin metrics
+const OPERATION_APPLY = "apply"
+const OPERATION_REVOKE = "revoke"
+
+// Values of the STATE label of the namespace gauge
+const NAMESPACE_READY = "ready"
+const NAMESPACE_NOT_READY = "not_ready"
+const NAMESPACE_TERMINATING = "terminating"
+
+var namespaceStates = []string{NAMESPACE_READY, NAMESPACE_NOT_READY, NAMESPACE_TERMINATING}
 
 var (
 	// Counter metrics
-	// num of requests counter vec
+	// num of requests counter vec, "kind" is one of a fixed set of
+	// descriptions, never built from errors or object names
 	requestCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "request_kf",
@@ -42,6 +65,53 @@ var (
 		Name: "service_heartbeat",
 		Help: "Heartbeat signal every 10 seconds indicating pods are alive.",
 	}, []string{COMPONENT, SEVERITY})
+
+	// Gauge metrics of the resource quota of the profiles
+	quotaHardGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "profile_quota_hard",
+		Help: "Hard limit of the resource quota of the profile namespace",
+	}, []string{PROFILELABEL, RESOURCE})
+	quotaUsedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "profile_quota_used",
+		Help: "Usage of the resource quota of the profile namespace",
+	}, []string{PROFILELABEL, RESOURCE})
+
+	// The resources with quota gauges of each profile, to delete the ones
+	// which are no longer in the quota.
+	quotaResources     = map[string]map[corev1.ResourceName]bool{}
+	quotaResourcesLock sync.Mutex
+
+	// Histogram of the duration of the reconciliations, which include the
+	// wait for the creation of the namespace
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "profile_reconcile_duration_seconds",
+		Help:    "Duration of the reconciliations of the profiles",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{RESULT})
+
+	// Gauge metrics of the readiness of the profiles and their namespaces
+	profileReadyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "profile_ready",
+		Help: "Whether the Ready condition of the profile is True",
+	}, []string{PROFILELABEL})
+	namespaceGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "profile_namespaces",
+		Help: "Number of profile namespaces per state",
+	}, []string{STATE})
+
+	// The namespace state of each profile, the namespace gauge counts them
+	namespaceStatesByProfile = map[string]string{}
+	namespaceStatesLock      sync.Mutex
+
+	// Metrics of the plugins, by kind
+	pluginDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "profile_plugin_duration_seconds",
+		Help: "Duration of the application and revocation of the plugins of profiles",
+	}, []string{KIND, OPERATION})
+	pluginErrorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "profile_plugin_errors_total",
+		Help: "Number of failed applications and revocations of the plugins of profiles",
+	}, []string{KIND, OPERATION})
 )
 
 func init() {
@@ -49,6 +119,16 @@ func init() {
 	metrics.Registry.MustRegister(requestCounter)
 	metrics.Registry.MustRegister(requestErrorCounter)
 	metrics.Registry.MustRegister(serviceHeartbeat)
+	metrics.Registry.MustRegister(quotaHardGauge)
+	metrics.Registry.MustRegister(quotaUsedGauge)
+	metrics.Registry.MustRegister(reconcileDuration)
+	metrics.Registry.MustRegister(profileReadyGauge)
+	metrics.Registry.MustRegister(namespaceGauge)
+	metrics.Registry.MustRegister(pluginDuration)
+	metrics.Registry.MustRegister(pluginErrorCounter)
+	for _, state := range namespaceStates {
+		namespaceGauge.With(prometheus.Labels{STATE: state}).Set(0)
+	}
 	// Count heartbeat
 	go func() {
 		labels := prometheus.Labels{COMPONENT: PROFILE, SEVERITY: SEVERITY_CRITICAL}
@@ -60,18 +140,107 @@ func init() {
 }
 
 func IncRequestCounter(kind string) {
-	if len(kind) > MAX_TAG_LEN {
-		kind = kind[0:MAX_TAG_LEN]
-	}
 	labels := prometheus.Labels{COMPONENT: PROFILE, KIND: kind}
 	requestCounter.With(labels).Inc()
 }
 
 func IncRequestErrorCounter(kind string, severity string) {
-	if len(kind) > MAX_TAG_LEN {
-		kind = kind[0:MAX_TAG_LEN]
-	}
 	labels := prometheus.Labels{COMPONENT: PROFILE, KIND: kind, SEVERITY: severity}
 	log.Errorf("Failed request with kind: %v", kind)
 	requestErrorCounter.With(labels).Inc()
 }
+
+// SetQuotaMetrics sets the quota gauges of the profile, and deletes the ones
+// of resources which are no longer in the quota. A nil quota deletes all of them.
+func SetQuotaMetrics(profile string, quota *corev1.ResourceQuotaStatus) {
+	quotaResourcesLock.Lock()
+	defer quotaResourcesLock.Unlock()
+	resources := map[corev1.ResourceName]bool{}
+	if quota != nil {
+		for name, hard := range quota.Hard {
+			labels := prometheus.Labels{PROFILELABEL: profile, RESOURCE: string(name)}
+			used := quota.Used[name]
+			quotaHardGauge.With(labels).Set(float64(hard.MilliValue()) / 1000)
+			quotaUsedGauge.With(labels).Set(float64(used.MilliValue()) / 1000)
+			resources[name] = true
+		}
+	}
+	for name := range quotaResources[profile] {
+		if !resources[name] {
+			labels := prometheus.Labels{PROFILELABEL: profile, RESOURCE: string(name)}
+			quotaHardGauge.Delete(labels)
+			quotaUsedGauge.Delete(labels)
+		}
+	}
+	if len(resources) == 0 {
+		delete(quotaResources, profile)
+	} else {
+		quotaResources[profile] = resources
+	}
+}
+
+// resultLabel returns the RESULT label of an operation which returned "err".
+func resultLabel(err error) string {
+	if err != nil {
+		return RESULT_ERROR
+	}
+	return RESULT_SUCCESS
+}
+
+// ObserveReconcile records the duration of a reconciliation started at
+// "start", which returned "err".
+func ObserveReconcile(start time.Time, err error) {
+	reconcileDuration.With(prometheus.Labels{RESULT: resultLabel(err)}).Observe(time.Since(start).Seconds())
+}
+
+// ObservePlugin records the duration of the application or revocation of a
+// plugin of kind "kind" started at "start", and counts it if it failed.
+func ObservePlugin(kind, operation string, start time.Time, err error) {
+	labels := prometheus.Labels{KIND: kind, OPERATION: operation}
+	pluginDuration.With(labels).Observe(time.Since(start).Seconds())
+	if err != nil {
+		pluginErrorCounter.With(labels).Inc()
+	}
+}
+
+// SetProfileMetrics sets the readiness gauge of the profile, and counts its
+// namespace in the state given by its status.
+func SetProfileMetrics(profile *profilev1.Profile, status *profilev1.ProfileStatus) {
+	ready := 0.0
+	if c := getCondition(status, profilev1.ProfileReady); c != nil && c.Status == string(corev1.ConditionTrue) {
+		ready = 1
+	}
+	state := NAMESPACE_NOT_READY
+	if !profile.DeletionTimestamp.IsZero() {
+		state = NAMESPACE_TERMINATING
+	} else if c := getCondition(status, profilev1.NamespaceReady); c != nil && c.Status == string(corev1.ConditionTrue) {
+		state = NAMESPACE_READY
+	}
+	namespaceStatesLock.Lock()
+	defer namespaceStatesLock.Unlock()
+	profileReadyGauge.With(prometheus.Labels{PROFILELABEL: profile.Name}).Set(ready)
+	namespaceStatesByProfile[profile.Name] = state
+	setNamespaceGauge()
+}
+
+// DeleteProfileMetrics deletes the readiness gauge of a deleted profile, and
+// stops counting its namespace.
+func DeleteProfileMetrics(profile string) {
+	namespaceStatesLock.Lock()
+	defer namespaceStatesLock.Unlock()
+	profileReadyGauge.Delete(prometheus.Labels{PROFILELABEL: profile})
+	delete(namespaceStatesByProfile, profile)
+	setNamespaceGauge()
+}
+
+// setNamespaceGauge counts the namespaces per state, namespaceStatesLock
+// must be held.
+func setNamespaceGauge() {
+	counts := map[string]int{}
+	for _, state := range namespaceStatesByProfile {
+		counts[state]++
+	}
+	for _, state := range namespaceStates {
+		namespaceGauge.With(prometheus.Labels{STATE: state}).Set(float64(counts[state]))
+	}
+}
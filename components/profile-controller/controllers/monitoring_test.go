@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func readyStatus(ready, namespaceReady corev1.ConditionStatus) *profilev1.ProfileStatus {
+	return &profilev1.ProfileStatus{Conditions: []profilev1.ProfileCondition{
+		{Type: profilev1.ProfileReady, Status: string(ready)},
+		{Type: profilev1.NamespaceReady, Status: string(namespaceReady)},
+	}}
+}
+
+func TestProfileMetrics(t *testing.T) {
+	now := metav1.Now()
+	alice := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "metrics-alice"}}
+	bob := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "metrics-bob"}}
+	carol := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "metrics-carol", DeletionTimestamp: &now}}
+	// Other tests may have set the metrics of their profiles
+	before := map[string]float64{}
+	for _, state := range namespaceStates {
+		before[state] = testutil.ToFloat64(namespaceGauge.With(prometheus.Labels{STATE: state}))
+	}
+	SetProfileMetrics(alice, readyStatus(corev1.ConditionTrue, corev1.ConditionTrue))
+	SetProfileMetrics(bob, readyStatus(corev1.ConditionFalse, corev1.ConditionFalse))
+	SetProfileMetrics(carol, readyStatus(corev1.ConditionFalse, corev1.ConditionTrue))
+	defer DeleteProfileMetrics(carol.Name)
+	defer DeleteProfileMetrics(bob.Name)
+
+	tests := []struct {
+		gauge    prometheus.Collector
+		expected float64
+	}{
+		{profileReadyGauge.With(prometheus.Labels{PROFILELABEL: alice.Name}), 1},
+		{profileReadyGauge.With(prometheus.Labels{PROFILELABEL: bob.Name}), 0},
+		{namespaceGauge.With(prometheus.Labels{STATE: NAMESPACE_READY}), before[NAMESPACE_READY] + 1},
+		{namespaceGauge.With(prometheus.Labels{STATE: NAMESPACE_NOT_READY}), before[NAMESPACE_NOT_READY] + 1},
+		{namespaceGauge.With(prometheus.Labels{STATE: NAMESPACE_TERMINATING}), before[NAMESPACE_TERMINATING] + 1},
+	}
+	for _, test := range tests {
+		if value := testutil.ToFloat64(test.gauge); value != test.expected {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.expected, value)
+		}
+	}
+
+	// Deleted profiles aren't counted anymore
+	DeleteProfileMetrics(alice.Name)
+	if value := testutil.ToFloat64(namespaceGauge.With(prometheus.Labels{STATE: NAMESPACE_READY})); value != before[NAMESPACE_READY] {
+		t.Errorf("Expect:\n%v; Output:\n%v", before[NAMESPACE_READY], value)
+	}
+	if profileReadyGauge.Delete(prometheus.Labels{PROFILELABEL: alice.Name}) {
+		t.Errorf("Expect:\nno gauge of %v; Output:\ngauge deleted", alice.Name)
+	}
+}
+
+func TestObservePlugin(t *testing.T) {
+	labels := prometheus.Labels{KIND: "MetricsTest", OPERATION: OPERATION_APPLY}
+	ObservePlugin("MetricsTest", OPERATION_APPLY, time.Now(), nil)
+	ObservePlugin("MetricsTest", OPERATION_APPLY, time.Now(), errors.New("failed"))
+	if value := testutil.ToFloat64(pluginErrorCounter.With(labels)); value != 1 {
+		t.Errorf("Expect:\n1; Output:\n%v", value)
+	}
+
+	// Kinds of requests aren't truncated
+	counter := requestCounter.With(prometheus.Labels{COMPONENT: PROFILE, KIND: "reject profile with invalid owners or contributors"})
+	before := testutil.ToFloat64(counter)
+	IncRequestCounter("reject profile with invalid owners or contributors")
+	if value := testutil.ToFloat64(counter); value != before+1 {
+		t.Errorf("Expect:\n%v; Output:\n%v", before+1, value)
+	}
+}
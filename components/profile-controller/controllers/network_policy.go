@@ -0,0 +1,185 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Names of the default NetworkPolicies of a profile namespace
+const KFINGRESSPOLICY = "kf-ingress-policy"
+const KFEGRESSPOLICY = "kf-egress-policy"
+
+// Label set by Kubernetes on every namespace, with the name of the namespace
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// networkPoliciesEnabled returns whether the default NetworkPolicies are
+// created for "profileIns".
+func (r *ProfileReconciler) networkPoliciesEnabled(profileIns *profilev1.Profile) bool {
+	if profileIns.Spec.NetworkPolicy != nil && profileIns.Spec.NetworkPolicy.Enabled != nil {
+		return *profileIns.Spec.NetworkPolicy.Enabled
+	}
+	return r.NetworkPolicies
+}
+
+// getNetworkPolicies returns the default NetworkPolicies of the namespace of
+// "profileIns": ingress is only allowed from the namespace itself and the
+// allowed namespaces, and egress is restricted when the profile has egress
+// rules.
+func (r *ProfileReconciler) getNetworkPolicies(profileIns *profilev1.Profile) []*networkingv1.NetworkPolicy {
+	if !r.networkPoliciesEnabled(profileIns) {
+		return nil
+	}
+	namespaces := append([]string{}, r.NetworkPolicyNamespaces...)
+	var egress []networkingv1.NetworkPolicyEgressRule
+	if profileIns.Spec.NetworkPolicy != nil {
+		namespaces = append(namespaces, profileIns.Spec.NetworkPolicy.AllowedNamespaces...)
+		egress = defaultEgressProtocols(profileIns.Spec.NetworkPolicy.Egress)
+	}
+	from := []networkingv1.NetworkPolicyPeer{
+		{
+			PodSelector: &metav1.LabelSelector{},
+		},
+	}
+	if len(namespaces) > 0 {
+		from = append(from, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{
+						Key:      namespaceNameLabel,
+						Operator: metav1.LabelSelectorOpIn,
+						Values:   namespaces,
+					},
+				},
+			},
+		})
+	}
+	policies := []*networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      KFINGRESSPOLICY,
+				Namespace: profileIns.Name,
+			},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						From: from,
+					},
+				},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		},
+	}
+	if len(egress) > 0 {
+		policies = append(policies, &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      KFEGRESSPOLICY,
+				Namespace: profileIns.Name,
+			},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				Egress:      egress,
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			},
+		})
+	}
+	return policies
+}
+
+// defaultEgressProtocols returns a copy of the rules with the protocol of the
+// ports defaulted like the API server does, so that they can be compared with
+// the existing NetworkPolicy.
+func defaultEgressProtocols(rules []networkingv1.NetworkPolicyEgressRule) []networkingv1.NetworkPolicyEgressRule {
+	defaulted := []networkingv1.NetworkPolicyEgressRule{}
+	for _, rule := range rules {
+		rule := *rule.DeepCopy()
+		for i := range rule.Ports {
+			if rule.Ports[i].Protocol == nil {
+				protocol := corev1.ProtocolTCP
+				rule.Ports[i].Protocol = &protocol
+			}
+		}
+		defaulted = append(defaulted, rule)
+	}
+	return defaulted
+}
+
+// updateNetworkPolicies create or update the default NetworkPolicies of the
+// namespace of "profileIns", and delete the ones which are no longer needed.
+func (r *ProfileReconciler) updateNetworkPolicies(profileIns *profilev1.Profile) error {
+	ctx := context.Background()
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	desired := map[string]bool{}
+	for _, policy := range r.getNetworkPolicies(profileIns) {
+		desired[policy.Name] = true
+		if err := controllerutil.SetControllerReference(profileIns, policy, r.Scheme); err != nil {
+			return err
+		}
+		found := &networkingv1.NetworkPolicy{}
+		err := r.Get(ctx, types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}, found)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			logger.Info("Creating NetworkPolicy", "namespace", policy.Namespace, "name", policy.Name)
+			if err := r.Create(ctx, policy); err != nil {
+				return err
+			}
+			continue
+		}
+		if !reflect.DeepEqual(policy.Spec, found.Spec) {
+			r.recordDrift(profileIns, "NetworkPolicy", found, "spec")
+			found.Spec = policy.Spec
+			logger.Info("Updating NetworkPolicy", "namespace", policy.Namespace, "name", policy.Name)
+			if err := r.Update(ctx, found); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range []string{KFINGRESSPOLICY, KFEGRESSPOLICY} {
+		if desired[name] {
+			continue
+		}
+		found := &networkingv1.NetworkPolicy{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: profileIns.Name}, found)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !metav1.IsControlledBy(found, profileIns) {
+			continue
+		}
+		logger.Info("Deleting NetworkPolicy", "namespace", found.Namespace, "name", found.Name)
+		if err := r.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGetNetworkPolicies(t *testing.T) {
+	enabled, disabled := true, false
+	port := intstr.FromInt(443)
+	tests := []struct {
+		globalEnabled bool
+		networkPolicy *profilev1.ProfileNetworkPolicy
+		expectedNames []string
+		namespaces    []string
+	}{
+		{false, nil, []string{}, nil},
+		{true, nil, []string{KFINGRESSPOLICY}, []string{"istio-system", "kubeflow"}},
+		{true, &profilev1.ProfileNetworkPolicy{Enabled: &disabled}, []string{}, nil},
+		{
+			false,
+			&profilev1.ProfileNetworkPolicy{
+				Enabled:           &enabled,
+				AllowedNamespaces: []string{"monitoring"},
+				Egress: []networkingv1.NetworkPolicyEgressRule{
+					{Ports: []networkingv1.NetworkPolicyPort{{Port: &port}}},
+				},
+			},
+			[]string{KFINGRESSPOLICY, KFEGRESSPOLICY},
+			[]string{"istio-system", "kubeflow", "monitoring"},
+		},
+	}
+	for _, test := range tests {
+		r := &ProfileReconciler{
+			NetworkPolicies:         test.globalEnabled,
+			NetworkPolicyNamespaces: []string{"istio-system", "kubeflow"},
+		}
+		profile := &profilev1.Profile{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+			Spec:       profilev1.ProfileSpec{NetworkPolicy: test.networkPolicy},
+		}
+		policies := r.getNetworkPolicies(profile)
+		names := []string{}
+		for _, policy := range policies {
+			names = append(names, policy.Name)
+			if policy.Namespace != "alice" {
+				t.Errorf("Expect:\n%v; Output:\n%v", "alice", policy.Namespace)
+			}
+		}
+		if !reflect.DeepEqual(names, test.expectedNames) {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.expectedNames, names)
+		}
+		if len(policies) == 0 {
+			continue
+		}
+		from := policies[0].Spec.Ingress[0].From
+		if len(from) != 2 || !reflect.DeepEqual(from[1].NamespaceSelector.MatchExpressions[0].Values, test.namespaces) {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.namespaces, from)
+		}
+		if len(policies) == 2 {
+			protocol := policies[1].Spec.Egress[0].Ports[0].Protocol
+			if protocol == nil || *protocol != corev1.ProtocolTCP {
+				t.Errorf("Expect:\n%v; Output:\n%v", corev1.ProtocolTCP, protocol)
+			}
+			if test.networkPolicy.Egress[0].Ports[0].Protocol != nil {
+				t.Errorf("Expect:\nprofile unchanged; Output:\n%v", test.networkPolicy.Egress)
+			}
+		}
+	}
+}
@@ -0,0 +1,366 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// plugin kind
+	KIND_AZURE_WORKLOAD_IDENTITY = "AzureWorkloadIdentity"
+	AZURE_CLIENT_ID_ANNOTATION   = "azure.workload.identity/client-id"
+	AZURE_TENANT_ID_ANNOTATION   = "azure.workload.identity/tenant-id"
+	AZURE_USE_LABEL              = "azure.workload.identity/use"
+	AZURE_DEFAULT_AUDIENCE       = "api://AzureADTokenExchange"
+	AZURE_SUBJECT                = "system:serviceaccount:%s:%s"
+
+	azureDefaultAuthorityHost = "https://login.microsoftonline.com/"
+	azureResourceManager      = "https://management.azure.com"
+	azureManagedIdentityAPI   = "2023-01-31"
+)
+
+var azureGUIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AzureWorkloadIdentity: plugin that setup AKS workload identity (credentials for Azure APIs) for target profile
+// namespace.
+type AzureWorkloadIdentity struct {
+	// Client ID of the Azure AD application or user-assigned managed identity
+	ClientID string `json:"clientId,omitempty"`
+	// Tenant of the identity, the tenant of the cluster by default
+	TenantID string `json:"tenantId,omitempty"`
+	// The user-assigned managed identity the federated identity credential is
+	// created for. The credential isn't managed by the plugin when they are
+	// empty.
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	ResourceGroup  string `json:"resourceGroup,omitempty"`
+	IdentityName   string `json:"identityName,omitempty"`
+	// OIDC issuer URL of the cluster
+	Issuer string `json:"issuer,omitempty"`
+	// k8s service accounts of the namespace using the identity, DEFAULT_EDITOR by default. Each one gets its own
+	// federated identity credential.
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+
+	// Manages the federated identity credentials, the Azure Resource Manager
+	// API by default
+	credentials AzureFederatedCredentials
+}
+
+// AzureFederatedCredential is a federated identity credential of a
+// user-assigned managed identity, trusting the tokens of a ServiceAccount.
+type AzureFederatedCredential struct {
+	SubscriptionID string
+	ResourceGroup  string
+	IdentityName   string
+	Name           string
+	Issuer         string
+	Subject        string
+	Audiences      []string
+}
+
+// AzureFederatedCredentials manages federated identity credentials. Both
+// methods must be idempotent.
+type AzureFederatedCredentials interface {
+	CreateOrUpdate(ctx context.Context, credential AzureFederatedCredential) error
+	Delete(ctx context.Context, credential AzureFederatedCredential) error
+}
+
+func init() {
+	RegisterPlugin(PluginType{
+		Kind: KIND_AZURE_WORKLOAD_IDENTITY,
+		Schema: PluginSchema{
+			Properties: map[string]string{
+				"clientId":        "string",
+				"tenantId":        "string",
+				"subscriptionId":  "string",
+				"resourceGroup":   "string",
+				"identityName":    "string",
+				"issuer":          "string",
+				"serviceAccounts": "array",
+			},
+			Required: []string{"clientId"},
+		},
+		New: func() Plugin { return &AzureWorkloadIdentity{} },
+	})
+}
+
+// Validate checks that the IDs are GUIDs, and that the managed identity and
+// the issuer are all set when the federated identity credential is managed.
+func (azure *AzureWorkloadIdentity) Validate() error {
+	if !azureGUIDRegexp.MatchString(azure.ClientID) {
+		return fmt.Errorf("clientId %v is not a valid GUID", azure.ClientID)
+	}
+	if azure.TenantID != "" && !azureGUIDRegexp.MatchString(azure.TenantID) {
+		return fmt.Errorf("tenantId %v is not a valid GUID", azure.TenantID)
+	}
+	if err := validatePluginServiceAccounts(azure.ServiceAccounts); err != nil {
+		return err
+	}
+	if azure.SubscriptionID == "" && azure.ResourceGroup == "" && azure.IdentityName == "" && azure.Issuer == "" {
+		return nil
+	}
+	if azure.SubscriptionID == "" || azure.ResourceGroup == "" || azure.IdentityName == "" {
+		return fmt.Errorf("subscriptionId, resourceGroup and identityName must all be set to manage the federated identity credential")
+	}
+	if !strings.HasPrefix(azure.Issuer, "https://") {
+		return fmt.Errorf("issuer %v must be an https URL", azure.Issuer)
+	}
+	return nil
+}
+
+// ApplyPlugin annotates the service accounts (DEFAULT_EDITOR by default) with the client ID, and creates their
+// federated identity credentials
+func (azure *AzureWorkloadIdentity) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	for _, ksa := range pluginServiceAccounts(azure.ServiceAccounts, DEFAULT_EDITOR) {
+		logger.Info("Patch Annotation for service account: ", "namespace ", profile.Name, "name ", ksa)
+		if err := azure.patchServiceAccount(r, profile.Name, ksa, addAzureIdentityAnnotation); err != nil {
+			return err
+		}
+		if !azure.managesCredential() {
+			continue
+		}
+		logger.Info("Setting up federated identity credential.", "Identity", azure.IdentityName, "name", ksa)
+		err := azure.credentialsClient().CreateOrUpdate(context.Background(), azure.federatedCredential(profile.Name, ksa))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokePlugin removes the client ID from the service accounts, and deletes their federated identity credentials
+func (azure *AzureWorkloadIdentity) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	for _, ksa := range pluginServiceAccounts(azure.ServiceAccounts, DEFAULT_EDITOR) {
+		if azure.managesCredential() {
+			logger.Info("Clean up federated identity credential.", "Identity", azure.IdentityName, "name", ksa)
+			err := azure.credentialsClient().Delete(context.Background(), azure.federatedCredential(profile.Name, ksa))
+			if err != nil {
+				return err
+			}
+		}
+		err := azure.patchServiceAccount(r, profile.Name, ksa, removeAzureIdentityAnnotation)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchServiceAccount applies "patch" to the service account and updates it
+func (azure *AzureWorkloadIdentity) patchServiceAccount(r *ProfileReconciler, namespace string, ksa string,
+	patch func(*corev1.ServiceAccount, *AzureWorkloadIdentity)) error {
+	ctx := context.Background()
+	found := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ksa, Namespace: namespace}, found); err != nil {
+		return err
+	}
+	patch(found, azure)
+	return r.Update(ctx, found)
+}
+
+func (azure *AzureWorkloadIdentity) managesCredential() bool {
+	return azure.IdentityName != ""
+}
+
+func (azure *AzureWorkloadIdentity) credentialsClient() AzureFederatedCredentials {
+	if azure.credentials == nil {
+		azure.credentials = newAzureResourceManagerClient()
+	}
+	return azure.credentials
+}
+
+// federatedCredential returns the federated identity credential of the service account "ksa" of "namespace".
+func (azure *AzureWorkloadIdentity) federatedCredential(namespace, ksa string) AzureFederatedCredential {
+	return AzureFederatedCredential{
+		SubscriptionID: azure.SubscriptionID,
+		ResourceGroup:  azure.ResourceGroup,
+		IdentityName:   azure.IdentityName,
+		Name:           fmt.Sprintf("kubeflow-%v-%v", namespace, ksa),
+		Issuer:         azure.Issuer,
+		Subject:        fmt.Sprintf(AZURE_SUBJECT, namespace, ksa),
+		Audiences:      []string{AZURE_DEFAULT_AUDIENCE},
+	}
+}
+
+func addAzureIdentityAnnotation(sa *corev1.ServiceAccount, azure *AzureWorkloadIdentity) {
+	if sa.Annotations == nil {
+		sa.Annotations = map[string]string{}
+	}
+	sa.Annotations[AZURE_CLIENT_ID_ANNOTATION] = azure.ClientID
+	if azure.TenantID != "" {
+		sa.Annotations[AZURE_TENANT_ID_ANNOTATION] = azure.TenantID
+	} else {
+		delete(sa.Annotations, AZURE_TENANT_ID_ANNOTATION)
+	}
+	if sa.Labels == nil {
+		sa.Labels = map[string]string{}
+	}
+	sa.Labels[AZURE_USE_LABEL] = "true"
+}
+
+func removeAzureIdentityAnnotation(sa *corev1.ServiceAccount, azure *AzureWorkloadIdentity) {
+	delete(sa.Annotations, AZURE_CLIENT_ID_ANNOTATION)
+	delete(sa.Annotations, AZURE_TENANT_ID_ANNOTATION)
+	delete(sa.Labels, AZURE_USE_LABEL)
+}
+
+// azureResourceManagerClient manages federated identity credentials with the
+// Azure Resource Manager REST API. The controller authenticates with its own
+// workload identity, from the environment variables set by the Azure workload
+// identity webhook.
+type azureResourceManagerClient struct {
+	authorityHost   string
+	resourceManager string
+	clientID        string
+	tenantID        string
+	tokenFile       string
+	httpClient      *http.Client
+}
+
+func newAzureResourceManagerClient() *azureResourceManagerClient {
+	authorityHost := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authorityHost == "" {
+		authorityHost = azureDefaultAuthorityHost
+	}
+	return &azureResourceManagerClient{
+		authorityHost:   authorityHost,
+		resourceManager: azureResourceManager,
+		clientID:        os.Getenv("AZURE_CLIENT_ID"),
+		tenantID:        os.Getenv("AZURE_TENANT_ID"),
+		tokenFile:       os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// token exchanges the ServiceAccount token of the controller for an Azure AD
+// access token of the Resource Manager.
+func (c *azureResourceManagerClient) token(ctx context.Context) (string, error) {
+	if c.clientID == "" || c.tenantID == "" || c.tokenFile == "" {
+		return "", fmt.Errorf("AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_FEDERATED_TOKEN_FILE must be set to manage federated identity credentials")
+	}
+	assertion, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_id":             {c.clientID},
+		"scope":                 {c.resourceManager + "/.default"},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+	}
+	tokenURL := fmt.Sprintf("%v/%v/oauth2/v2.0/token", strings.TrimSuffix(c.authorityHost, "/"), c.tenantID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+func (c *azureResourceManagerClient) credentialURL(credential AzureFederatedCredential) string {
+	return fmt.Sprintf("%v/subscriptions/%v/resourceGroups/%v/providers/Microsoft.ManagedIdentity/"+
+		"userAssignedIdentities/%v/federatedIdentityCredentials/%v?api-version=%v",
+		c.resourceManager, url.PathEscape(credential.SubscriptionID), url.PathEscape(credential.ResourceGroup),
+		url.PathEscape(credential.IdentityName), url.PathEscape(credential.Name), azureManagedIdentityAPI)
+}
+
+func (c *azureResourceManagerClient) CreateOrUpdate(ctx context.Context, credential AzureFederatedCredential) error {
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"properties": map[string]interface{}{
+			"issuer":    credential.Issuer,
+			"subject":   credential.Subject,
+			"audiences": credential.Audiences,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.credentialURL(credential), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	_, err = c.do(req, http.StatusOK, http.StatusCreated)
+	return err
+}
+
+func (c *azureResourceManagerClient) Delete(ctx context.Context, credential AzureFederatedCredential) error {
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.credentialURL(credential), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	_, err = c.do(req, http.StatusOK, http.StatusNoContent, http.StatusNotFound)
+	return err
+}
+
+// do sends the request and returns the body of its response, or an error if
+// its status code isn't one of "expected".
+func (c *azureResourceManagerClient) do(req *http.Request, expected ...int) ([]byte, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return body, nil
+		}
+	}
+	return nil, fmt.Errorf("%v %v: status code %v: %v", req.Method, req.URL.Path, resp.StatusCode, string(body))
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const azureTestClientID = "00000000-1111-2222-3333-444444444444"
+
+// fakeAzureFederatedCredentials records the federated identity credentials.
+type fakeAzureFederatedCredentials struct {
+	credentials map[string]AzureFederatedCredential
+}
+
+func (f *fakeAzureFederatedCredentials) CreateOrUpdate(ctx context.Context, credential AzureFederatedCredential) error {
+	f.credentials[credential.Name] = credential
+	return nil
+}
+
+func (f *fakeAzureFederatedCredentials) Delete(ctx context.Context, credential AzureFederatedCredential) error {
+	delete(f.credentials, credential.Name)
+	return nil
+}
+
+func TestAzureWorkloadIdentityValidate(t *testing.T) {
+	tests := []struct {
+		plugin AzureWorkloadIdentity
+		error  string
+	}{
+		{AzureWorkloadIdentity{ClientID: azureTestClientID}, ""},
+		{AzureWorkloadIdentity{ClientID: "my-app"}, "not a valid GUID"},
+		{AzureWorkloadIdentity{ClientID: azureTestClientID, IdentityName: "kubeflow"}, "must all be set"},
+		{AzureWorkloadIdentity{ClientID: azureTestClientID, SubscriptionID: "sub", ResourceGroup: "rg",
+			IdentityName: "kubeflow", Issuer: "https://oidc.example.com/"}, ""},
+		{AzureWorkloadIdentity{ClientID: azureTestClientID, SubscriptionID: "sub", ResourceGroup: "rg",
+			IdentityName: "kubeflow"}, "must be an https URL"},
+	}
+	for _, test := range tests {
+		err := test.plugin.Validate()
+		if test.error == "" && err != nil || test.error != "" && (err == nil || !strings.Contains(err.Error(), test.error)) {
+			t.Errorf("Expect:\n%q; Output:\n%v", test.error, err)
+		}
+	}
+}
+
+func TestAzureWorkloadIdentityApplyRevoke(t *testing.T) {
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: DEFAULT_EDITOR, Namespace: "alice"}}
+	r := &ProfileReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, sa),
+		Log:    ctrl.Log,
+	}
+	credentials := &fakeAzureFederatedCredentials{credentials: map[string]AzureFederatedCredential{}}
+	plugin := &AzureWorkloadIdentity{
+		ClientID:       azureTestClientID,
+		SubscriptionID: "sub",
+		ResourceGroup:  "rg",
+		IdentityName:   "kubeflow",
+		Issuer:         "https://oidc.example.com/",
+		credentials:    credentials,
+	}
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "alice"}}
+
+	// Applying twice is the same as applying once
+	for i := 0; i < 2; i++ {
+		if err := plugin.ApplyPlugin(r, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found := &corev1.ServiceAccount{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: DEFAULT_EDITOR, Namespace: "alice"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Annotations[AZURE_CLIENT_ID_ANNOTATION] != azureTestClientID || found.Labels[AZURE_USE_LABEL] != "true" {
+		t.Errorf("Expect:\nclient ID annotation and use label; Output:\n%v %v", found.Annotations, found.Labels)
+	}
+	expected := map[string]AzureFederatedCredential{
+		"kubeflow-alice-default-editor": {
+			SubscriptionID: "sub",
+			ResourceGroup:  "rg",
+			IdentityName:   "kubeflow",
+			Name:           "kubeflow-alice-default-editor",
+			Issuer:         "https://oidc.example.com/",
+			Subject:        "system:serviceaccount:alice:default-editor",
+			Audiences:      []string{AZURE_DEFAULT_AUDIENCE},
+		},
+	}
+	if !reflect.DeepEqual(credentials.credentials, expected) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expected, credentials.credentials)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := plugin.RevokePlugin(r, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found = &corev1.ServiceAccount{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: DEFAULT_EDITOR, Namespace: "alice"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := found.Annotations[AZURE_CLIENT_ID_ANNOTATION]; ok || found.Labels[AZURE_USE_LABEL] != "" {
+		t.Errorf("Expect:\nno client ID annotation nor use label; Output:\n%v %v", found.Annotations, found.Labels)
+	}
+	if len(credentials.credentials) != 0 {
+		t.Errorf("Expect:\nno credential; Output:\n%v", credentials.credentials)
+	}
+}
+
+func TestAzureResourceManagerClient(t *testing.T) {
+	credentials := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/oauth2/v2.0/token") {
+			if err := req.ParseForm(); err != nil || req.Form.Get("client_assertion") != "sa-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token": "arm-token"}`))
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer arm-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.Method {
+		case http.MethodPut:
+			body, _ := ioutil.ReadAll(req.Body)
+			credentials[req.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			if _, ok := credentials[req.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(credentials, req.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "azure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("sa-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := &azureResourceManagerClient{
+		authorityHost:   server.URL + "/",
+		resourceManager: server.URL,
+		clientID:        azureTestClientID,
+		tenantID:        "tenant",
+		tokenFile:       tokenFile,
+		httpClient:      server.Client(),
+	}
+	credential := (&AzureWorkloadIdentity{SubscriptionID: "sub", ResourceGroup: "rg", IdentityName: "kubeflow",
+		Issuer: "https://oidc.example.com/"}).federatedCredential("alice", DEFAULT_EDITOR)
+
+	if err := c.CreateOrUpdate(context.Background(), credential); err != nil {
+		t.Fatal(err)
+	}
+	path := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/kubeflow/" +
+		"federatedIdentityCredentials/kubeflow-alice-default-editor"
+	body := map[string]map[string]interface{}{}
+	if err := json.Unmarshal([]byte(credentials[path]), &body); err != nil {
+		t.Fatalf("Expect:\ncredential at %v; Output:\n%v", path, credentials)
+	}
+	if body["properties"]["subject"] != "system:serviceaccount:alice:default-editor" {
+		t.Errorf("Expect:\n%v; Output:\n%v", "system:serviceaccount:alice:default-editor", body["properties"]["subject"])
+	}
+	// Deleting twice succeeds
+	for i := 0; i < 2; i++ {
+		if err := c.Delete(context.Background(), credential); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(credentials) != 0 {
+		t.Errorf("Expect:\nno credential; Output:\n%v", credentials)
+	}
+}
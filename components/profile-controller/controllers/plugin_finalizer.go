@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Annotation an administrator sets to "true" on a Profile being deleted to
+// remove its finalizer even though some plugins can't be revoked.
+const FORCEFINALIZEANNOTATION = "profiles.kubeflow.org/force-finalize"
+
+// forceFinalize returns whether the plugins which can't be revoked must be
+// skipped.
+func forceFinalize(profile *profilev1.Profile) bool {
+	return profile.Annotations[FORCEFINALIZEANNOTATION] == "true"
+}
+
+// pluginKey identifies a plugin by its kind and its spec, whatever the
+// formatting of the spec.
+func pluginKey(p profilev1.Plugin) string {
+	var spec interface{}
+	if p.Spec != nil && len(p.Spec.Raw) > 0 {
+		if err := json.Unmarshal(p.Spec.Raw, &spec); err != nil {
+			return p.Kind + "/" + string(p.Spec.Raw)
+		}
+	}
+	// Maps are marshalled with sorted keys
+	raw, _ := json.Marshal(spec)
+	return p.Kind + "/" + string(raw)
+}
+
+// validPluginSpecs returns the plugins which can be decoded.
+func validPluginSpecs(plugins []profilev1.Plugin) []profilev1.Plugin {
+	valid := []profilev1.Plugin{}
+	for _, p := range plugins {
+		if _, err := DecodePlugin(p); err == nil {
+			valid = append(valid, p)
+		}
+	}
+	return valid
+}
+
+// removedPlugins returns the plugins of "applied" which aren't in "plugins".
+// A plugin whose spec changed is removed, and applied again with its new
+// spec.
+func removedPlugins(applied, plugins []profilev1.Plugin) []profilev1.Plugin {
+	keys := map[string]bool{}
+	for _, p := range plugins {
+		keys[pluginKey(p)] = true
+	}
+	removed := []profilev1.Plugin{}
+	for _, p := range applied {
+		if !keys[pluginKey(p)] {
+			removed = append(removed, p)
+		}
+	}
+	return removed
+}
+
+// unionPlugins returns the plugins of "a", and the ones of "b" which aren't
+// in "a".
+func unionPlugins(a, b []profilev1.Plugin) []profilev1.Plugin {
+	union := append([]profilev1.Plugin{}, a...)
+	return append(union, removedPlugins(b, a)...)
+}
+
+// revokePlugins revokes each plugin, without stopping at the first error.
+// It returns the plugins which couldn't be revoked, which must be retried,
+// and the first error.
+func (r *ProfileReconciler) revokePlugins(profile *profilev1.Profile, plugins []profilev1.Plugin,
+	status *profileStatus) ([]profilev1.Plugin, error) {
+	logger := r.Log.WithValues("profile", profile.Name)
+	remaining := []profilev1.Plugin{}
+	var revokeErr error
+	for _, p := range plugins {
+		pluginIns, err := DecodePlugin(p)
+		if err == nil {
+			start := time.Now()
+			err = pluginIns.RevokePlugin(r, profile)
+			ObservePlugin(p.Kind, OPERATION_REVOKE, start, err)
+		}
+		if err == nil {
+			logger.Info("Revoked plugin", "kind", p.Kind)
+			continue
+		}
+		logger.Error(err, "error revoking plugin", "kind", p.Kind)
+		IncRequestErrorCounter("error revoking plugin", SEVERITY_MAJOR)
+		status.setPlugin(p.Kind, fmt.Errorf("revoking: %v", err))
+		remaining = append(remaining, p)
+		if revokeErr == nil {
+			revokeErr = fmt.Errorf("plugin %v: %v", p.Kind, err)
+		}
+	}
+	return remaining, revokeErr
+}
+
+// finalizePlugins revokes the applied plugins of a profile being deleted,
+// and the ones of its spec, which may have been partly applied. The plugins
+// which can't be revoked are skipped when the profile has the
+// FORCEFINALIZEANNOTATION annotation, otherwise the error is returned, to be
+// retried with backoff.
+func (r *ProfileReconciler) finalizePlugins(instance, profile *profilev1.Profile, status *profileStatus) error {
+	plugins := unionPlugins(instance.Status.AppliedPlugins, validPluginSpecs(profile.Spec.Plugins))
+	remaining, err := r.revokePlugins(profile, plugins, status)
+	status.setAppliedPlugins(remaining)
+	if err == nil || !forceFinalize(instance) {
+		return err
+	}
+	r.Log.Info("Skipping plugins which can't be revoked", "profile", instance.Name, "error", err.Error())
+	r.Recorder.Eventf(instance, corev1.EventTypeWarning, "PluginRevocationSkipped",
+		"Finalizing without revoking %v plugins, as %v is set: %v", len(remaining), FORCEFINALIZEANNOTATION, err)
+	status.setAppliedPlugins([]profilev1.Plugin{})
+	return nil
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const kindRevokeTest = "RevokeTest"
+
+// revokeTestPlugin fails to be revoked while its name is in revokeTestFailures.
+type revokeTestPlugin struct {
+	Name string `json:"name"`
+}
+
+var revokeTestFailures = map[string]bool{}
+var revokeTestRevoked = []string{}
+
+func (p *revokeTestPlugin) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	return nil
+}
+
+func (p *revokeTestPlugin) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	if revokeTestFailures[p.Name] {
+		return fmt.Errorf("%v can't be revoked", p.Name)
+	}
+	revokeTestRevoked = append(revokeTestRevoked, p.Name)
+	return nil
+}
+
+func revokeTestSpec(raw string) profilev1.Plugin {
+	return profilev1.Plugin{
+		TypeMeta: metav1.TypeMeta{Kind: kindRevokeTest},
+		Spec:     &runtime.RawExtension{Raw: []byte(raw)},
+	}
+}
+
+func TestPluginKey(t *testing.T) {
+	a := revokeTestSpec(`{"name": "a", "x": 1}`)
+	if pluginKey(a) != pluginKey(revokeTestSpec(`{"x":1,"name":"a"}`)) {
+		t.Errorf("Expect:\nsame key; Output:\n%v %v", pluginKey(a), pluginKey(revokeTestSpec(`{"x":1,"name":"a"}`)))
+	}
+	if pluginKey(a) == pluginKey(revokeTestSpec(`{"name": "a", "x": 2}`)) {
+		t.Errorf("Expect:\ndifferent keys; Output:\n%v", pluginKey(a))
+	}
+
+	b := revokeTestSpec(`{"name": "b"}`)
+	removed := removedPlugins([]profilev1.Plugin{a, b}, []profilev1.Plugin{revokeTestSpec(`{"x": 1, "name": "a"}`)})
+	if len(removed) != 1 || pluginKey(removed[0]) != pluginKey(b) {
+		t.Errorf("Expect:\n%v; Output:\n%v", []profilev1.Plugin{b}, removed)
+	}
+	if union := unionPlugins([]profilev1.Plugin{a}, []profilev1.Plugin{a, b}); len(union) != 2 {
+		t.Errorf("Expect:\n2 plugins; Output:\n%v", union)
+	}
+}
+
+func TestFinalizePlugins(t *testing.T) {
+	RegisterPlugin(PluginType{
+		Kind:   kindRevokeTest,
+		Schema: PluginSchema{Properties: map[string]string{"name": "string"}},
+		New:    func() Plugin { return &revokeTestPlugin{} },
+	})
+	defer delete(pluginTypes, kindRevokeTest)
+	revokeTestFailures = map[string]bool{"stuck": true}
+	revokeTestRevoked = []string{}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ProfileReconciler{Log: ctrl.Log, Recorder: recorder}
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec: profilev1.ProfileSpec{
+			Plugins: []profilev1.Plugin{revokeTestSpec(`{"name": "current"}`)},
+		},
+		Status: profilev1.ProfileStatus{
+			AppliedPlugins: []profilev1.Plugin{revokeTestSpec(`{"name": "stuck"}`), revokeTestSpec(`{"name": "old"}`)},
+		},
+	}
+
+	// All the plugins are revoked, the ones which fail are kept
+	status := &profileStatus{}
+	if err := r.finalizePlugins(profile, profile, status); err == nil {
+		t.Errorf("Expect:\nrevocation error; Output:\n%v", err)
+	}
+	if len(revokeTestRevoked) != 2 || revokeTestRevoked[0] != "old" || revokeTestRevoked[1] != "current" {
+		t.Errorf("Expect:\n[old current]; Output:\n%v", revokeTestRevoked)
+	}
+	if len(status.appliedPlugins) != 1 || pluginKey(status.appliedPlugins[0]) != pluginKey(revokeTestSpec(`{"name": "stuck"}`)) {
+		t.Errorf("Expect:\nstuck plugin kept; Output:\n%v", status.appliedPlugins)
+	}
+	if len(status.plugins) != 1 || status.plugins[0].Status != "False" {
+		t.Errorf("Expect:\none failed plugin status; Output:\n%v", status.plugins)
+	}
+
+	// The administrator forces the finalization
+	profile.Annotations = map[string]string{FORCEFINALIZEANNOTATION: "true"}
+	status = &profileStatus{}
+	if err := r.finalizePlugins(profile, profile, status); err != nil {
+		t.Errorf("Expect:\nnil; Output:\n%v", err)
+	}
+	if status.appliedPlugins == nil || len(status.appliedPlugins) != 0 {
+		t.Errorf("Expect:\nno applied plugins; Output:\n%v", status.appliedPlugins)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("Expect:\nPluginRevocationSkipped event; Output:\n%v events", len(recorder.Events))
+	}
+}
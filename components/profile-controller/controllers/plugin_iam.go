@@ -27,26 +27,59 @@ const (
 
 type AwsIAMForServiceAccount struct {
 	AwsIAMRole string `json:"awsIamRole,omitempty"`
+	// k8s service accounts of the namespace which can assume AwsIAMRole, DEFAULT_EDITOR by default
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
 }
 
-// ApplyPlugin annotate service account with the ARN of the IAM role and update trust relationship of IAM role
+func init() {
+	RegisterPlugin(PluginType{
+		Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT,
+		Schema: PluginSchema{
+			Properties: map[string]string{"awsIamRole": "string", "serviceAccounts": "array"},
+			Required:   []string{"awsIamRole"},
+		},
+		New: func() Plugin { return &AwsIAMForServiceAccount{} },
+	})
+}
+
+// Validate checks that AwsIAMRole is the ARN of an IAM role
+func (aws *AwsIAMForServiceAccount) Validate() error {
+	if !strings.HasPrefix(aws.AwsIAMRole, "arn:") || !strings.Contains(aws.AwsIAMRole, ":role/") {
+		return fmt.Errorf("%v is not a valid IAM role ARN", aws.AwsIAMRole)
+	}
+	return validatePluginServiceAccounts(aws.ServiceAccounts)
+}
+
+// ApplyPlugin annotate the service accounts (DEFAULT_EDITOR by default) with the ARN of the IAM role and update
+// trust relationship of IAM role
 func (aws *AwsIAMForServiceAccount) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
 	logger := r.Log.WithValues("profile", profile.Name)
-	if err := aws.patchAnnotation(r, profile.Name, DEFAULT_EDITOR, addIAMRoleAnnotation, logger); err != nil {
-		return err
+	for _, ksa := range pluginServiceAccounts(aws.ServiceAccounts, DEFAULT_EDITOR) {
+		if err := aws.patchAnnotation(r, profile.Name, ksa, addIAMRoleAnnotation, logger); err != nil {
+			return err
+		}
+		logger.Info("Setting up iam roles and policy for service account.", "ServiceAccount", aws.AwsIAMRole,
+			"name", ksa)
+		if err := aws.updateIAMForServiceAccount(profile.Name, ksa, addServiceAccountInAssumeRolePolicy); err != nil {
+			return err
+		}
 	}
-	logger.Info("Setting up iam roles and policy for service account.", "ServiceAccount", aws.AwsIAMRole)
-	return aws.updateIAMForServiceAccount(profile.Name, DEFAULT_EDITOR, addServiceAccountInAssumeRolePolicy)
+	return nil
 }
 
 // RevokePlugin remove role in service account annotation and delete service account record in IAM trust relationship.
 func (aws *AwsIAMForServiceAccount) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
 	logger := r.Log.WithValues("profile", profile.Name)
-	if err := aws.patchAnnotation(r, profile.Name, DEFAULT_EDITOR, removeIAMRoleAnnotation, logger); err != nil {
-		return err
+	for _, ksa := range pluginServiceAccounts(aws.ServiceAccounts, DEFAULT_EDITOR) {
+		if err := aws.patchAnnotation(r, profile.Name, ksa, removeIAMRoleAnnotation, logger); err != nil {
+			return err
+		}
+		logger.Info("Clean up AWS IAM Role for Service Account.", "ServiceAccount", aws.AwsIAMRole, "name", ksa)
+		if err := aws.updateIAMForServiceAccount(profile.Name, ksa, removeServiceAccountInAssumeRolePolicy); err != nil {
+			return err
+		}
 	}
-	logger.Info("Clean up AWS IAM Role for Service Account.", "ServiceAccount", aws.AwsIAMRole)
-	return aws.updateIAMForServiceAccount(profile.Name, DEFAULT_EDITOR, removeServiceAccountInAssumeRolePolicy)
+	return nil
 }
 
 // patchAnnotation will patch annotation to k8s service account in order to pair up with GCP identity
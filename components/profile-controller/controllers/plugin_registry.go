@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+)
+
+// PluginSchema describes the spec of a kind of plugin. Specs with fields
+// which aren't in Properties, or missing Required fields, are rejected.
+type PluginSchema struct {
+	// The fields of the spec, mapped to their JSON type: string, boolean,
+	// integer, number, object or array
+	Properties map[string]string
+	// The fields which must be set
+	Required []string
+}
+
+// PluginType is a kind of plugin known by the controller.
+type PluginType struct {
+	// The kind of the plugin in the profile spec
+	Kind string
+	// The schema of the spec of the plugin
+	Schema PluginSchema
+	// Returns an empty plugin, the spec is unmarshalled into it
+	New func() Plugin
+}
+
+// PluginValidator is implemented by the plugins which check their spec
+// beyond what their schema describes.
+type PluginValidator interface {
+	Validate() error
+}
+
+// pluginTypes maps the kinds of plugins to their type.
+var pluginTypes = map[string]PluginType{}
+
+// RegisterPlugin adds a kind of plugin to the registry, usually in the init
+// function of the file implementing the plugin.
+func RegisterPlugin(pluginType PluginType) {
+	if _, ok := pluginTypes[pluginType.Kind]; ok {
+		panic(fmt.Sprintf("plugin kind %v registered twice", pluginType.Kind))
+	}
+	pluginTypes[pluginType.Kind] = pluginType
+}
+
+// PluginKinds returns the registered kinds of plugins, sorted.
+func PluginKinds() []string {
+	kinds := []string{}
+	for kind := range pluginTypes {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// PluginSpecError is the error of a plugin of a profile which isn't
+// registered or whose spec is invalid.
+type PluginSpecError struct {
+	Kind string
+	Err  error
+}
+
+func (e *PluginSpecError) Error() string {
+	return fmt.Sprintf("plugin %v: %v", e.Kind, e.Err)
+}
+
+// DecodePlugin validates the spec of "p" against the schema of its kind, and
+// returns the plugin it describes.
+func DecodePlugin(p profilev1.Plugin) (Plugin, error) {
+	pluginType, ok := pluginTypes[p.Kind]
+	if !ok {
+		return nil, &PluginSpecError{Kind: p.Kind, Err: fmt.Errorf("unknown plugin kind, must be one of %v", PluginKinds())}
+	}
+	raw := []byte("{}")
+	if p.Spec != nil && len(p.Spec.Raw) > 0 {
+		raw = p.Spec.Raw
+	}
+	spec := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, &PluginSpecError{Kind: p.Kind, Err: fmt.Errorf("spec must be an object: %v", err)}
+	}
+	if err := pluginType.Schema.validate(spec); err != nil {
+		return nil, &PluginSpecError{Kind: p.Kind, Err: err}
+	}
+	pluginIns := pluginType.New()
+	if err := json.Unmarshal(raw, pluginIns); err != nil {
+		return nil, &PluginSpecError{Kind: p.Kind, Err: err}
+	}
+	if validator, ok := pluginIns.(PluginValidator); ok {
+		if err := validator.Validate(); err != nil {
+			return nil, &PluginSpecError{Kind: p.Kind, Err: err}
+		}
+	}
+	return pluginIns, nil
+}
+
+// ValidatePlugins returns the errors of the plugins which can't be decoded.
+func ValidatePlugins(plugins []profilev1.Plugin) []error {
+	errs := []error{}
+	for _, p := range plugins {
+		if _, err := DecodePlugin(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validate checks the fields of "spec" against the schema.
+func (s PluginSchema) validate(spec map[string]interface{}) error {
+	for _, field := range s.Required {
+		if _, ok := spec[field]; !ok {
+			return fmt.Errorf("spec.%v is required", field)
+		}
+	}
+	fields := []string{}
+	for field := range spec {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fieldType, ok := s.Properties[field]
+		if !ok {
+			return fmt.Errorf("unknown field spec.%v", field)
+		}
+		if !hasJSONType(spec[field], fieldType) {
+			return fmt.Errorf("spec.%v must be of type %v", field, fieldType)
+		}
+	}
+	return nil
+}
+
+// hasJSONType returns whether the unmarshalled JSON value is of the JSON
+// schema type "jsonType".
+func hasJSONType(value interface{}, jsonType string) bool {
+	switch v := value.(type) {
+	case string:
+		return jsonType == "string"
+	case bool:
+		return jsonType == "boolean"
+	case float64:
+		return jsonType == "number" || (jsonType == "integer" && v == math.Trunc(v))
+	case map[string]interface{}:
+		return jsonType == "object"
+	case []interface{}:
+		return jsonType == "array"
+	default:
+		return false
+	}
+}
+
+// pluginKind returns the kind of a plugin returned by GetPluginSpec.
+func pluginKind(plugin Plugin) string {
+	pluginType := reflect.TypeOf(plugin)
+	for kind, t := range pluginTypes {
+		if reflect.TypeOf(t.New()) == pluginType {
+			return kind
+		}
+	}
+	return fmt.Sprintf("%T", plugin)
+}
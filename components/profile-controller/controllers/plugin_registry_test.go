@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestDecodePlugin(t *testing.T) {
+	tests := []struct {
+		kind  string
+		spec  string
+		error string
+	}{
+		{
+			kind: KIND_WORKLOAD_IDENTITY,
+			spec: `{"gcpServiceAccount": "sa@project.iam.gserviceaccount.com"}`,
+		},
+		{
+			kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT,
+			spec: `{"awsIamRole": "arn:aws:iam::34892524:role/s3-reader"}`,
+		},
+		{
+			kind:  "Unknown",
+			spec:  `{}`,
+			error: "unknown plugin kind",
+		},
+		{
+			kind:  KIND_WORKLOAD_IDENTITY,
+			spec:  `{}`,
+			error: "spec.gcpServiceAccount is required",
+		},
+		{
+			kind:  KIND_WORKLOAD_IDENTITY,
+			spec:  `{"gcpServiceAccount": "sa@project.iam.gserviceaccount.com", "gcpServiceAcount": "typo"}`,
+			error: "unknown field spec.gcpServiceAcount",
+		},
+		{
+			kind:  KIND_AWS_IAM_FOR_SERVICE_ACCOUNT,
+			spec:  `{"awsIamRole": 42}`,
+			error: "spec.awsIamRole must be of type string",
+		},
+		{
+			kind:  KIND_AWS_IAM_FOR_SERVICE_ACCOUNT,
+			spec:  `{"awsIamRole": "s3-reader"}`,
+			error: "not a valid IAM role ARN",
+		},
+		{
+			kind:  KIND_AWS_IAM_FOR_SERVICE_ACCOUNT,
+			spec:  `["s3-reader"]`,
+			error: "spec must be an object",
+		},
+	}
+	for _, test := range tests {
+		plugin := profilev1.Plugin{
+			TypeMeta: metav1.TypeMeta{Kind: test.kind},
+			Spec:     &runtime.RawExtension{Raw: []byte(test.spec)},
+		}
+		pluginIns, err := DecodePlugin(plugin)
+		if test.error == "" {
+			if err != nil {
+				t.Errorf("Expect:\nno error; Output:\n%v", err)
+			} else if kind := pluginKind(pluginIns); kind != test.kind {
+				t.Errorf("Expect:\n%v; Output:\n%v", test.kind, kind)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.error, err)
+		}
+	}
+}
+
+func TestHasJSONType(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		jsonType string
+		expected bool
+	}{
+		{"a", "string", true},
+		{true, "boolean", true},
+		{float64(3), "integer", true},
+		{3.5, "integer", false},
+		{3.5, "number", true},
+		{map[string]interface{}{}, "object", true},
+		{[]interface{}{}, "array", true},
+		{nil, "string", false},
+	}
+	for _, test := range tests {
+		if output := hasJSONType(test.value, test.jsonType); output != test.expected {
+			t.Errorf("%v %v: Expect:\n%v; Output:\n%v", test.value, test.jsonType, test.expected, output)
+		}
+	}
+}
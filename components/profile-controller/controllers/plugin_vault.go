@@ -0,0 +1,260 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+)
+
+const (
+	// plugin kind
+	KIND_VAULT = "Vault"
+
+	vaultDefaultAuthMount   = "kubernetes"
+	vaultDefaultSecretMount = "secret"
+	vaultDefaultPathPrefix  = "kubeflow"
+)
+
+var vaultPathRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$`)
+
+// Vault: plugin that gives the pods of the target profile namespace access to
+// their own path of a HashiCorp Vault KV version 2 secrets engine, through
+// the Kubernetes auth method. Vault and the paths are configured by the
+// environment of the controller, never by the profile, which could send the
+// token of the controller elsewhere or get access to other paths:
+//   - VAULT_ADDR and VAULT_TOKEN: the address of Vault and the token managing the policies and roles
+//   - VAULT_AUTH_MOUNT: mount path of the Kubernetes auth method, kubernetes by default
+//   - VAULT_SECRET_MOUNT: mount path of the KV version 2 secrets engine, secret by default
+//   - VAULT_PATH_PREFIX: prefix of the paths of the profiles, kubeflow by default. The
+//     secrets of a profile are under <secret mount>/<prefix>/<profile>/.
+type Vault struct {
+	// TTL of the Vault tokens of the pods, e.g. 1h. The default of the auth
+	// method by default.
+	TokenTTL string `json:"tokenTTL,omitempty"`
+	// Service accounts of the namespace bound to the role, DEFAULT_EDITOR and
+	// DEFAULT_VIEWER by default
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+
+	// Set from the environment when client is nil
+	client      VaultClient
+	authMount   string
+	secretMount string
+	pathPrefix  string
+}
+
+// VaultKubernetesRole is a role of the Vault Kubernetes auth method.
+type VaultKubernetesRole struct {
+	BoundServiceAccountNames      []string `json:"bound_service_account_names"`
+	BoundServiceAccountNamespaces []string `json:"bound_service_account_namespaces"`
+	TokenPolicies                 []string `json:"token_policies"`
+	TokenTTL                      string   `json:"token_ttl,omitempty"`
+}
+
+// VaultClient manages the Vault policies and Kubernetes auth roles. All the
+// methods must be idempotent.
+type VaultClient interface {
+	PutPolicy(name, policy string) error
+	DeletePolicy(name string) error
+	PutKubernetesRole(mount, name string, role VaultKubernetesRole) error
+	DeleteKubernetesRole(mount, name string) error
+}
+
+func init() {
+	RegisterPlugin(PluginType{
+		Kind: KIND_VAULT,
+		Schema: PluginSchema{
+			Properties: map[string]string{"tokenTTL": "string", "serviceAccounts": "array"},
+		},
+		New: func() Plugin { return &Vault{} },
+	})
+}
+
+// Validate checks the TTL and the service accounts
+func (vault *Vault) Validate() error {
+	if vault.TokenTTL != "" {
+		if _, err := time.ParseDuration(vault.TokenTTL); err != nil {
+			return fmt.Errorf("tokenTTL: %v", err)
+		}
+	}
+	return validatePluginServiceAccounts(vault.ServiceAccounts)
+}
+
+// ApplyPlugin creates the policy of the profile path, and the role of the service accounts
+func (vault *Vault) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	if err := vault.configure(); err != nil {
+		return err
+	}
+	name := vaultName(profile.Name)
+	logger.Info("Setting up Vault policy and role.", "policy", name, "path", vault.secretPath(profile.Name))
+	if err := vault.client.PutPolicy(name, vault.policy(profile.Name)); err != nil {
+		return err
+	}
+	return vault.client.PutKubernetesRole(vault.authMount, name, VaultKubernetesRole{
+		BoundServiceAccountNames:      pluginServiceAccounts(vault.ServiceAccounts, DEFAULT_EDITOR, DEFAULT_VIEWER),
+		BoundServiceAccountNamespaces: []string{profile.Name},
+		TokenPolicies:                 []string{name},
+		TokenTTL:                      vault.TokenTTL,
+	})
+}
+
+// RevokePlugin deletes the role and the policy of the profile. The secrets are kept.
+func (vault *Vault) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	if err := vault.configure(); err != nil {
+		return err
+	}
+	name := vaultName(profile.Name)
+	logger.Info("Clean up Vault policy and role.", "policy", name)
+	if err := vault.client.DeleteKubernetesRole(vault.authMount, name); err != nil {
+		return err
+	}
+	return vault.client.DeletePolicy(name)
+}
+
+// vaultName returns the name of the policy and the role of a profile
+func vaultName(profile string) string {
+	return "kubeflow-profile-" + profile
+}
+
+// secretPath returns the path of the secrets of the profile, relative to the secrets engine
+func (vault *Vault) secretPath(profile string) string {
+	return vault.pathPrefix + "/" + profile
+}
+
+// policy returns the policy giving access to the secrets of the profile,
+// and only them.
+func (vault *Vault) policy(profile string) string {
+	return fmt.Sprintf(`path "%[1]v/data/%[2]v/*" {
+  capabilities = ["create", "read", "update", "delete", "list"]
+}
+path "%[1]v/metadata/%[2]v/*" {
+  capabilities = ["read", "delete", "list"]
+}
+`, vault.secretMount, vault.secretPath(profile))
+}
+
+// configure sets the client and the paths from the environment, unless the
+// client is already set.
+func (vault *Vault) configure() error {
+	if vault.client != nil {
+		return nil
+	}
+	address := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		return fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set to manage Vault policies and roles")
+	}
+	paths := map[string]*string{
+		"VAULT_AUTH_MOUNT":   &vault.authMount,
+		"VAULT_SECRET_MOUNT": &vault.secretMount,
+		"VAULT_PATH_PREFIX":  &vault.pathPrefix,
+	}
+	defaults := map[string]string{
+		"VAULT_AUTH_MOUNT":   vaultDefaultAuthMount,
+		"VAULT_SECRET_MOUNT": vaultDefaultSecretMount,
+		"VAULT_PATH_PREFIX":  vaultDefaultPathPrefix,
+	}
+	for env, path := range paths {
+		*path = strings.Trim(os.Getenv(env), "/")
+		if *path == "" {
+			*path = defaults[env]
+		}
+		if !vaultPathRegexp.MatchString(*path) {
+			return fmt.Errorf("%v %v is not a valid Vault path", env, *path)
+		}
+	}
+	vault.client = &vaultHTTPClient{
+		address:    strings.TrimSuffix(address, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	return nil
+}
+
+// vaultHTTPClient calls the HTTP API of Vault with the token of the
+// controller, which must be allowed to manage the policies and the roles of
+// the Kubernetes auth method.
+type vaultHTTPClient struct {
+	address    string
+	token      string
+	httpClient *http.Client
+}
+
+func (c *vaultHTTPClient) PutPolicy(name, policy string) error {
+	return c.do(http.MethodPut, "sys/policies/acl/"+url.PathEscape(name), map[string]string{"policy": policy})
+}
+
+func (c *vaultHTTPClient) DeletePolicy(name string) error {
+	return c.do(http.MethodDelete, "sys/policies/acl/"+url.PathEscape(name), nil)
+}
+
+func (c *vaultHTTPClient) PutKubernetesRole(mount, name string, role VaultKubernetesRole) error {
+	return c.do(http.MethodPost, "auth/"+mount+"/role/"+url.PathEscape(name), role)
+}
+
+func (c *vaultHTTPClient) DeleteKubernetesRole(mount, name string) error {
+	return c.do(http.MethodDelete, "auth/"+mount+"/role/"+url.PathEscape(name), nil)
+}
+
+// do sends a request to the path of the Vault API. Deleting an object which
+// doesn't exist succeeds in Vault.
+func (c *vaultHTTPClient) do(method, path string, body interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, c.address+"/v1/"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	vaultErr := struct {
+		Errors []string `json:"errors"`
+	}{}
+	if err := json.Unmarshal(data, &vaultErr); err == nil && len(vaultErr.Errors) > 0 {
+		return fmt.Errorf("vault %v %v: status code %v: %v", method, path, resp.StatusCode, strings.Join(vaultErr.Errors, ", "))
+	}
+	return fmt.Errorf("vault %v %v: status code %v", method, path, resp.StatusCode)
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// fakeVault is a Vault HTTP API storing the policies and the roles.
+type fakeVault struct {
+	token    string
+	policies map[string]string
+	roles    map[string]VaultKubernetesRole
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("X-Vault-Token") != f.token {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors": ["permission denied"]}`))
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/v1/sys/policies/acl/"):
+		name := strings.TrimPrefix(req.URL.Path, "/v1/sys/policies/acl/")
+		if req.Method == http.MethodDelete {
+			delete(f.policies, name)
+			break
+		}
+		policy := map[string]string{}
+		json.Unmarshal(body, &policy)
+		f.policies[name] = policy["policy"]
+	case strings.HasPrefix(req.URL.Path, "/v1/auth/kubernetes/role/"):
+		name := strings.TrimPrefix(req.URL.Path, "/v1/auth/kubernetes/role/")
+		if req.Method == http.MethodDelete {
+			delete(f.roles, name)
+			break
+		}
+		role := VaultKubernetesRole{}
+		json.Unmarshal(body, &role)
+		f.roles[name] = role
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors": []}`))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestVaultValidate(t *testing.T) {
+	tests := []struct {
+		plugin Vault
+		error  string
+	}{
+		{Vault{}, ""},
+		{Vault{TokenTTL: "1h"}, ""},
+		{Vault{TokenTTL: "forever"}, "tokenTTL"},
+	}
+	for _, test := range tests {
+		err := test.plugin.Validate()
+		if test.error == "" && err != nil || test.error != "" && (err == nil || !strings.Contains(err.Error(), test.error)) {
+			t.Errorf("Expect:\n%q; Output:\n%v", test.error, err)
+		}
+	}
+}
+
+func TestVaultConfigure(t *testing.T) {
+	os.Setenv("VAULT_ADDR", "https://vault.example.com:8200")
+	os.Setenv("VAULT_TOKEN", "controller-token")
+	os.Setenv("VAULT_SECRET_MOUNT", "/kv/teams/")
+	defer os.Unsetenv("VAULT_ADDR")
+	defer os.Unsetenv("VAULT_TOKEN")
+	defer os.Unsetenv("VAULT_SECRET_MOUNT")
+	plugin := &Vault{}
+	if err := plugin.configure(); err != nil {
+		t.Fatal(err)
+	}
+	if plugin.authMount != "kubernetes" || plugin.secretMount != "kv/teams" || plugin.pathPrefix != "kubeflow" {
+		t.Errorf("Expect:\nkubernetes kv/teams kubeflow; Output:\n%v %v %v", plugin.authMount, plugin.secretMount, plugin.pathPrefix)
+	}
+
+	os.Setenv("VAULT_PATH_PREFIX", "../other")
+	defer os.Unsetenv("VAULT_PATH_PREFIX")
+	if err := (&Vault{}).configure(); err == nil || !strings.Contains(err.Error(), "not a valid Vault path") {
+		t.Errorf("Expect:\nnot a valid Vault path; Output:\n%v", err)
+	}
+}
+
+func TestVaultApplyRevoke(t *testing.T) {
+	vault := &fakeVault{token: "controller-token", policies: map[string]string{}, roles: map[string]VaultKubernetesRole{}}
+	server := httptest.NewServer(vault)
+	defer server.Close()
+	r := &ProfileReconciler{Log: ctrl.Log}
+	plugin := &Vault{
+		TokenTTL:    "1h",
+		client:      &vaultHTTPClient{address: server.URL, token: "controller-token", httpClient: server.Client()},
+		authMount:   vaultDefaultAuthMount,
+		secretMount: vaultDefaultSecretMount,
+		pathPrefix:  vaultDefaultPathPrefix,
+	}
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "alice"}}
+
+	for i := 0; i < 2; i++ {
+		if err := plugin.ApplyPlugin(r, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectedPolicy := `path "secret/data/kubeflow/alice/*" {
+  capabilities = ["create", "read", "update", "delete", "list"]
+}
+path "secret/metadata/kubeflow/alice/*" {
+  capabilities = ["read", "delete", "list"]
+}
+`
+	if vault.policies["kubeflow-profile-alice"] != expectedPolicy {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedPolicy, vault.policies)
+	}
+	expectedRole := VaultKubernetesRole{
+		BoundServiceAccountNames:      []string{DEFAULT_EDITOR, DEFAULT_VIEWER},
+		BoundServiceAccountNamespaces: []string{"alice"},
+		TokenPolicies:                 []string{"kubeflow-profile-alice"},
+		TokenTTL:                      "1h",
+	}
+	if !reflect.DeepEqual(vault.roles["kubeflow-profile-alice"], expectedRole) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedRole, vault.roles)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := plugin.RevokePlugin(r, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(vault.policies) != 0 || len(vault.roles) != 0 {
+		t.Errorf("Expect:\nno policy nor role; Output:\n%v %v", vault.policies, vault.roles)
+	}
+
+	plugin.client = &vaultHTTPClient{address: server.URL, token: "wrong-token", httpClient: server.Client()}
+	if err := plugin.ApplyPlugin(r, profile); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expect:\npermission denied; Output:\n%v", err)
+	}
+}
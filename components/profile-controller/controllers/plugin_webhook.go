@@ -0,0 +1,212 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+)
+
+const (
+	// plugin kind
+	KIND_WEBHOOK = "Webhook"
+	// Version of the requests and responses of webhook plugins
+	WEBHOOK_PLUGIN_API_VERSION = "plugins.kubeflow.org/v1"
+	WEBHOOK_ACTION_APPLY       = "apply"
+	WEBHOOK_ACTION_REVOKE      = "revoke"
+
+	webhookDefaultTimeoutSeconds = 10
+	webhookMaxTimeoutSeconds     = 30
+	// Size limit of the responses of webhook plugins
+	webhookMaxResponseBytes = 64 * 1024
+)
+
+// WebhookPlugin delegates the plugin to an HTTPS endpoint outside of the
+// controller: a WebhookPluginRequest is POSTed to it when the profile is
+// applied or revoked. Each reconciliation makes one attempt, failures are
+// retried with the backoff of the reconciliations.
+type WebhookPlugin struct {
+	// Name of the webhook, in the errors
+	Name string `json:"name,omitempty"`
+	// HTTPS URL of the endpoint
+	URL string `json:"url"`
+	// PEM encoded CA certificates (base64 encoded in the spec) verifying the
+	// endpoint, instead of the system CAs
+	CABundle []byte `json:"caBundle,omitempty"`
+	// Timeout of each attempt, 10 seconds by default
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// WebhookPluginRequest is the body of the requests to webhook plugins.
+type WebhookPluginRequest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// apply or revoke
+	Action  string             `json:"action"`
+	Profile *profilev1.Profile `json:"profile"`
+}
+
+// WebhookPluginResponse is the optional body of the responses of webhook
+// plugins. A response with a 2xx status code and no body is a success.
+type WebhookPluginResponse struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	// False when the action failed, true by default
+	Success *bool `json:"success,omitempty"`
+	// Reported in the status of the plugin when the action failed
+	Message string `json:"message,omitempty"`
+}
+
+func init() {
+	RegisterPlugin(PluginType{
+		Kind: KIND_WEBHOOK,
+		Schema: PluginSchema{
+			Properties: map[string]string{
+				"name":           "string",
+				"url":            "string",
+				"caBundle":       "string",
+				"timeoutSeconds": "integer",
+			},
+			Required: []string{"url"},
+		},
+		New: func() Plugin { return &WebhookPlugin{} },
+	})
+}
+
+// Validate checks that URL is an HTTPS URL, and the limits of the timeout.
+func (w *WebhookPlugin) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("url %v must be an https URL", w.URL)
+	}
+	if w.TimeoutSeconds < 0 || w.TimeoutSeconds > webhookMaxTimeoutSeconds {
+		return fmt.Errorf("timeoutSeconds must be between 0 and %v", webhookMaxTimeoutSeconds)
+	}
+	if len(w.CABundle) > 0 && !x509.NewCertPool().AppendCertsFromPEM(w.CABundle) {
+		return fmt.Errorf("caBundle has no valid PEM encoded certificate")
+	}
+	return nil
+}
+
+// ApplyPlugin POSTs the apply action to the webhook
+func (w *WebhookPlugin) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	r.Log.Info("Calling webhook plugin", "profile", profile.Name, "webhook", w.URL, "action", WEBHOOK_ACTION_APPLY)
+	return w.call(WEBHOOK_ACTION_APPLY, profile)
+}
+
+// RevokePlugin POSTs the revoke action to the webhook, which must be idempotent
+func (w *WebhookPlugin) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	r.Log.Info("Calling webhook plugin", "profile", profile.Name, "webhook", w.URL, "action", WEBHOOK_ACTION_REVOKE)
+	return w.call(WEBHOOK_ACTION_REVOKE, profile)
+}
+
+// call POSTs the action to the webhook. The attempt is bounded by the
+// timeout of the webhook, and isn't retried: the error is returned to the
+// reconciliation, which is retried with backoff.
+func (w *WebhookPlugin) call(action string, profile *profilev1.Profile) error {
+	body, err := json.Marshal(WebhookPluginRequest{
+		APIVersion: WEBHOOK_PLUGIN_API_VERSION,
+		Kind:       "WebhookPluginRequest",
+		Action:     action,
+		Profile:    profile,
+	})
+	if err != nil {
+		return err
+	}
+	client, err := w.client()
+	if err != nil {
+		return err
+	}
+	if err = w.post(client, body); err != nil {
+		name := w.Name
+		if name == "" {
+			name = w.URL
+		}
+		return fmt.Errorf("webhook %v %v failed: %v", name, action, err)
+	}
+	return nil
+}
+
+// client returns the HTTP client of the webhook, trusting CABundle when it's
+// set.
+func (w *WebhookPlugin) client() (*http.Client, error) {
+	timeout := w.TimeoutSeconds
+	if timeout == 0 {
+		timeout = webhookDefaultTimeoutSeconds
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(w.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(w.CABundle) {
+			return nil, fmt.Errorf("caBundle has no valid PEM encoded certificate")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport, Timeout: time.Duration(timeout) * time.Second}, nil
+}
+
+// post makes one attempt to call the webhook, and interprets its response.
+func (w *WebhookPlugin) post(client *http.Client, body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > webhookMaxResponseBytes {
+		return fmt.Errorf("status code %v: response larger than %v bytes", resp.StatusCode, webhookMaxResponseBytes)
+	}
+
+	result := WebhookPluginResponse{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &result); err != nil {
+			result.Message = string(data)
+			if resp.StatusCode/100 == 2 {
+				return fmt.Errorf("invalid response: %v", err)
+			}
+		}
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status code %v: %v", resp.StatusCode, result.Message)
+	}
+	if result.Success != nil && !*result.Success {
+		return fmt.Errorf("%v", result.Message)
+	}
+	return nil
+}
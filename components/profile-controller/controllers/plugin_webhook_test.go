@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWebhookPluginValidate(t *testing.T) {
+	tests := []struct {
+		plugin WebhookPlugin
+		error  string
+	}{
+		{WebhookPlugin{URL: "https://ldap-sync.example.com/profiles"}, ""},
+		{WebhookPlugin{URL: "http://ldap-sync.example.com/profiles"}, "must be an https URL"},
+		{WebhookPlugin{URL: "https://ldap-sync.example.com", TimeoutSeconds: 60}, "timeoutSeconds"},
+		{WebhookPlugin{URL: "https://ldap-sync.example.com", CABundle: []byte("not a certificate")}, "caBundle"},
+	}
+	for _, test := range tests {
+		err := test.plugin.Validate()
+		if test.error == "" && err != nil || test.error != "" && (err == nil || !strings.Contains(err.Error(), test.error)) {
+			t.Errorf("Expect:\n%q; Output:\n%v", test.error, err)
+		}
+	}
+}
+
+func TestWebhookPluginCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		error  string
+	}{
+		{
+			name:   "success without body",
+			status: http.StatusOK,
+		},
+		{
+			name:   "success with body",
+			status: http.StatusOK,
+			body:   `{"apiVersion": "plugins.kubeflow.org/v1", "kind": "WebhookPluginResponse", "success": true}`,
+		},
+		{
+			name:   "server error",
+			status: http.StatusInternalServerError,
+			body:   `{"message": "ldap unavailable"}`,
+			error:  "status code 500: ldap unavailable",
+		},
+		{
+			name:   "client error",
+			status: http.StatusBadRequest,
+			body:   `{"message": "unknown group"}`,
+			error:  "status code 400: unknown group",
+		},
+		{
+			name:   "failure reported in the response",
+			status: http.StatusOK,
+			body:   `{"success": false, "message": "quota exceeded"}`,
+			error:  "quota exceeded",
+		},
+		{
+			name:   "invalid response",
+			status: http.StatusOK,
+			body:   `ok`,
+			error:  "invalid response",
+		},
+		{
+			name:   "response too large",
+			status: http.StatusOK,
+			body:   strings.Repeat(" ", webhookMaxResponseBytes+1),
+			error:  "response larger than",
+		},
+	}
+	for _, test := range tests {
+		calls := 0
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			request := WebhookPluginRequest{}
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				t.Errorf("%v: invalid request: %v", test.name, err)
+			}
+			if request.APIVersion != WEBHOOK_PLUGIN_API_VERSION || request.Action != WEBHOOK_ACTION_APPLY ||
+				request.Profile.Name != "alice" {
+				t.Errorf("%v: Expect:\napply alice; Output:\n%v", test.name, request)
+			}
+			calls++
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+		plugin := &WebhookPlugin{
+			URL:      server.URL,
+			CABundle: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+		}
+		err := plugin.call(WEBHOOK_ACTION_APPLY, &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "alice"}})
+		server.Close()
+
+		if test.error == "" && err != nil || test.error != "" && (err == nil || !strings.Contains(err.Error(), test.error)) {
+			t.Errorf("%v: Expect:\n%q; Output:\n%v", test.name, test.error, err)
+		}
+		// Failures aren't retried within the reconciliation
+		if calls != 1 {
+			t.Errorf("%v: Expect:\n1 call; Output:\n%v calls", test.name, calls)
+		}
+	}
+}
+
+func TestWebhookPluginUntrustedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+	plugin := &WebhookPlugin{URL: server.URL}
+	err := plugin.call(WEBHOOK_ACTION_REVOKE, &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "alice"}})
+	if err == nil {
+		t.Errorf("Expect:\ncertificate error; Output:\nnil")
+	}
+}
@@ -26,6 +26,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"regexp"
+	"strings"
 )
 
 // plugin kind
@@ -38,16 +39,40 @@ const WORKLOAD_IDENTITY_ROLE = "roles/iam.workloadIdentityUser"
 // GcpWorkloadIdentity: plugin that setup GKE workload identity (credentials for GCP API) for target profile namespace.
 type GcpWorkloadIdentity struct {
 	GcpServiceAccount string `json:"gcpServiceAccount,omitempty"`
+	// k8s service accounts of the namespace bound to GcpServiceAccount, DEFAULT_EDITOR by default
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
 }
 
-// ApplyPlugin will grant GCP workload identity to service account DEFAULT_EDITOR
+func init() {
+	RegisterPlugin(PluginType{
+		Kind: KIND_WORKLOAD_IDENTITY,
+		Schema: PluginSchema{
+			Properties: map[string]string{"gcpServiceAccount": "string", "serviceAccounts": "array"},
+			Required:   []string{"gcpServiceAccount"},
+		},
+		New: func() Plugin { return &GcpWorkloadIdentity{} },
+	})
+}
+
+// Validate checks that GcpServiceAccount is the email of a GCP service account
+func (gcp *GcpWorkloadIdentity) Validate() error {
+	if !strings.HasSuffix(gcp.GcpServiceAccount, GCP_SA_SUFFIX) {
+		return fmt.Errorf("%v is not a valid GCP service account.", gcp.GcpServiceAccount)
+	}
+	return validatePluginServiceAccounts(gcp.ServiceAccounts)
+}
+
+// ApplyPlugin will grant GCP workload identity to the k8s service accounts, DEFAULT_EDITOR by default
 func (gcp *GcpWorkloadIdentity) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
 	logger := r.Log.WithValues("profile", profile.Name)
-	if err := gcp.patchAnnotation(r, profile.Name, DEFAULT_EDITOR, logger); err != nil {
-		return err
+	ksas := pluginServiceAccounts(gcp.ServiceAccounts, DEFAULT_EDITOR)
+	for _, ksa := range ksas {
+		if err := gcp.patchAnnotation(r, profile.Name, ksa, logger); err != nil {
+			return err
+		}
 	}
 	logger.Info("Setting up iam policy.", "ServiceAccount", gcp.GcpServiceAccount)
-	return gcp.updateWorkloadIdentity(profile.Name, DEFAULT_EDITOR, addBinding)
+	return gcp.updateWorkloadIdentity(profile.Name, ksas, addBinding)
 }
 
 // GetProjectID will return GCP project id of GcpServiceAccount. Will return empty string if cannot parse GcpServiceAccount
@@ -81,8 +106,9 @@ func (gcp *GcpWorkloadIdentity) patchAnnotation(r *ProfileReconciler, namespace
 	return r.Update(ctx, found)
 }
 
-// updateWorkloadIdentity update GCP service account IAM binding with provided binding update function f
-func (gcp *GcpWorkloadIdentity) updateWorkloadIdentity(namespace string, ksa string, f func(*iam.Policy, string)) error {
+// updateWorkloadIdentity update GCP service account IAM binding of each k8s service account with provided binding
+// update function f
+func (gcp *GcpWorkloadIdentity) updateWorkloadIdentity(namespace string, ksas []string, f func(*iam.Policy, string)) error {
 	projectID, err := gcp.GetProjectID()
 	if err != nil {
 		return err
@@ -120,8 +146,10 @@ func (gcp *GcpWorkloadIdentity) updateWorkloadIdentity(namespace string, ksa str
 	if ksaProjectID == "" {
 		ksaProjectID = projectID
 	}
-	bindingMember := fmt.Sprintf("serviceAccount:%v.svc.id.goog[%v/%v]", ksaProjectID, namespace, ksa)
-	f(currentPolicy, bindingMember)
+	for _, ksa := range ksas {
+		bindingMember := fmt.Sprintf("serviceAccount:%v.svc.id.goog[%v/%v]", ksaProjectID, namespace, ksa)
+		f(currentPolicy, bindingMember)
+	}
 
 	// Set iam policy
 	req := &iam.SetIamPolicyRequest{
@@ -156,5 +184,5 @@ func revokeBinding(currentPolicy *iam.Policy, member string) {
 func (gcp *GcpWorkloadIdentity) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
 	logger := r.Log.WithValues("profile", profile.Name)
 	logger.Info("Clean up Gcp Workload Identity.", "ServiceAccount", gcp.GcpServiceAccount)
-	return gcp.updateWorkloadIdentity(profile.Name, DEFAULT_EDITOR, revokeBinding)
+	return gcp.updateWorkloadIdentity(profile.Name, pluginServiceAccounts(gcp.ServiceAccounts, DEFAULT_EDITOR), revokeBinding)
 }
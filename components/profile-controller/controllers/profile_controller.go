@@ -18,16 +18,23 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/ghodss/yaml"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/cenkalti/backoff"
 	"github.com/go-logr/logr"
@@ -55,15 +62,8 @@ const USER = "user"
 const ROLE = "role"
 const ADMIN = "admin"
 
-// Kubeflow default role names
-// TODO: Make kubeflow roles configurable (krishnadurai)
-// This will enable customization of roles.
-const (
-	kubeflowAdmin       = "kubeflow-admin"
-	kubeflowEdit        = "kubeflow-edit"
-	kubeflowView        = "kubeflow-view"
-	istioInjectionLabel = "istio-injection"
-)
+// Label of the namespaces whose pods get an Istio sidecar
+const istioInjectionLabel = "istio-injection"
 
 var kubeflowNamespaceLabels = map[string]string{
 	"katib-metricscollector-injection":      "enabled",
@@ -72,6 +72,10 @@ var kubeflowNamespaceLabels = map[string]string{
 	"app.kubernetes.io/part-of":             "kubeflow-profile",
 }
 
+// Label of the RoleBindings generated for spec.owners, used to prune the
+// ones of removed owners.
+const OWNERBINDINGLABEL = "profiles.kubeflow.org/owner-binding"
+
 const DEFAULT_EDITOR = "default-editor"
 const DEFAULT_VIEWER = "default-viewer"
 
@@ -90,19 +94,45 @@ type ProfileReconciler struct {
 	Log              logr.Logger
 	UserIdHeader     string
 	UserIdPrefix     string
+	GroupsHeader     string
 	WorkloadIdentity string
+	// Grants access to the services of the namespaces, e.g. with Istio
+	Authorization AuthorizationBackend
+	Recorder      record.EventRecorder
+	// Usage ratios of the quota of a resource which emit an Event when crossed
+	QuotaThresholds []float64
+	// Whether default NetworkPolicies are created for profiles which don't
+	// set it, and the namespaces they allow ingress traffic from
+	NetworkPolicies         bool
+	NetworkPolicyNamespaces []string
+	// Roles of owners, contributors and service accounts, the default ones if
+	// nil
+	Roles *RoleCatalog
 }
 
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs="*"
 // +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs="*"
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs="*"
 // +kubebuilder:rbac:groups=security.istio.io,resources=authorizationpolicies,verbs="*"
+// +kubebuilder:rbac:groups=core,resources=limitranges;resourcequotas,verbs="*"
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs="*"
 // +kubebuilder:rbac:groups=kubeflow.org,resources=profiles;profiles/status;profiles/finalizers,verbs="*"
+// +kubebuilder:rbac:groups=kubeflow.org,resources=profiletemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kubeflow.org,resources=poddefaults,verbs="*"
 
 // Reconcile reads that state of the cluster for a Profile object and makes changes based on the state read
 // and what is in the Profile.Spec
 // Automatically generate RBAC rules to allow the Controller to read and write Deployments
 func (r *ProfileReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+	result, err := r.reconcile(request)
+	ObserveReconcile(start, err)
+	return result, err
+}
+
+// reconcile reconciles the profile, Reconcile records its duration.
+func (r *ProfileReconciler) reconcile(request ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	logger := r.Log.WithValues("profile", request.NamespacedName)
 
@@ -115,6 +145,8 @@ func (r *ProfileReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error)
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
 			IncRequestCounter("profile deletion")
+			SetQuotaMetrics(request.Name, nil)
+			DeleteProfileMetrics(request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -123,22 +155,60 @@ func (r *ProfileReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error)
 		return reconcile.Result{}, err
 	}
 
+	// A profile being deleted is only finalized
+	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, instance)
+	}
+
+	status := &profileStatus{}
+	if err := validateAccess(instance, r.roles()); err != nil {
+		IncRequestCounter("reject profile with invalid owners or contributors")
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "InvalidSubjects", err.Error(), nil)
+	}
+	if err := validateServiceAccounts(instance, r.roles()); err != nil {
+		IncRequestCounter("reject profile with invalid service accounts")
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "InvalidServiceAccounts", err.Error(), nil)
+	}
+
+	// The profile is reconciled with the defaults of its template, but they
+	// are never written to the Profile itself.
+	template, err := r.getTemplate(ctx, instance)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			IncRequestErrorCounter("error reading profile template", SEVERITY_MAJOR)
+			logger.Error(err, "error reading profile template")
+			return r.failAndReturn(ctx, instance, status, profilev1.TemplateReady, "TemplateReadFailed", err.Error(), err)
+		}
+		IncRequestCounter("reject profile with missing template")
+		return r.failAndReturn(ctx, instance, status, profilev1.TemplateReady, "TemplateNotFound",
+			fmt.Sprintf("profile template %v not found", instance.Spec.TemplateRef.Name), nil)
+	}
+	if template != nil {
+		if err := validateTemplate(template, r.Authorization.NamespaceLabels()); err != nil {
+			IncRequestCounter("reject profile with invalid template")
+			return r.failAndReturn(ctx, instance, status, profilev1.TemplateReady, "InvalidTemplate", err.Error(), nil)
+		}
+	}
+	profile := mergeTemplate(instance, template)
+
 	// Update namespace
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{"owner": instance.Spec.Owner.Name},
-			// inject istio sidecar to all pods in target namespace by default.
-			Labels: map[string]string{
-				istioInjectionLabel: "enabled",
+			Annotations: map[string]string{
+				"owner":             primaryOwner(instance).Name,
+				OWNERKINDANNOTATION: primaryOwner(instance).Kind,
 			},
-			Name: instance.Name,
+			// e.g. inject istio sidecar to all pods in target namespace.
+			Labels: r.Authorization.NamespaceLabels(),
+			Name:   instance.Name,
 		},
 	}
+	updateNamespaceMetadata(ns, template)
 	updateNamespaceLabels(ns)
 	if err := controllerutil.SetControllerReference(instance, ns, r.Scheme); err != nil {
 		IncRequestErrorCounter("error setting ControllerReference", SEVERITY_MAJOR)
 		logger.Error(err, "error setting ControllerReference")
-		return reconcile.Result{}, err
+		return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, "NamespaceCreateFailed", err.Error(), err)
 	}
 	foundNs := &corev1.Namespace{}
 	err = r.Get(ctx, types.NamespacedName{Name: ns.Name}, foundNs)
@@ -149,7 +219,7 @@ func (r *ProfileReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error)
 			if err != nil {
 				IncRequestErrorCounter("error creating namespace", SEVERITY_MAJOR)
 				logger.Error(err, "error creating namespace")
-				return reconcile.Result{}, err
+				return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, "NamespaceCreateFailed", err.Error(), err)
 			}
 			// wait 15 seconds for new namespace creation.
 			err = backoff.Retry(
@@ -160,60 +230,105 @@ func (r *ProfileReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error)
 			if err != nil {
 				IncRequestErrorCounter("error namespace create completion", SEVERITY_MAJOR)
 				logger.Error(err, "error namespace create completion")
-				return r.appendErrorConditionAndReturn(ctx, instance,
-					"Owning namespace failed to create within 15 seconds")
+				return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, "NamespaceCreateTimeout",
+					"Owning namespace failed to create within 15 seconds", nil)
 			}
 			logger.Info("Created Namespace: "+foundNs.Name, "status", foundNs.Status.Phase)
 		} else {
 			IncRequestErrorCounter("error reading namespace", SEVERITY_MAJOR)
 			logger.Error(err, "error reading namespace")
-			return reconcile.Result{}, err
+			return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, "NamespaceReadFailed", err.Error(), err)
 		}
 	} else {
 		// Check exising namespace ownership before move forward
-		owner, ok := foundNs.Annotations["owner"]
-		if ok && owner == instance.Spec.Owner.Name {
-			if updated := updateNamespaceLabels(foundNs); updated {
+		if ownsNamespace(instance, foundNs) {
+			updated := updateNamespaceMetadata(foundNs, template)
+			// The owner annotations follow the primary owner, on the namespaces the
+			// profile controls only: an uncontrolled namespace is only taken over by
+			// adoption
+			if metav1.IsControlledBy(foundNs, instance) && setOwnerAnnotations(foundNs, instance) {
+				updated = true
+			}
+			if updateNamespaceLabels(foundNs) {
+				updated = true
+			}
+			// The labels of the authorization backend, e.g. the Istio sidecar injection, can't be changed
+			if drifted := enforceLabels(foundNs, r.Authorization.NamespaceLabels()); len(drifted) > 0 {
+				r.recordDrift(instance, "Namespace", foundNs, drifted...)
+				updated = true
+			}
+			if updated {
 				err = r.Update(ctx, foundNs)
 				if err != nil {
 					IncRequestErrorCounter("error updating namespace label", SEVERITY_MAJOR)
 					logger.Error(err, "error updating namespace label")
-					return reconcile.Result{}, err
+					return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, "NamespaceUpdateFailed", err.Error(), err)
 				}
 			}
-		} else {
-			logger.Info(fmt.Sprintf("namespace already exist, but not owned by profile creator %v",
-				instance.Spec.Owner.Name))
+		} else if reason, msg := adoptionBlocked(instance, foundNs); reason != "" {
+			logger.Info(msg)
 			IncRequestCounter("reject profile taking over existing namespace")
-			return r.appendErrorConditionAndReturn(ctx, instance, fmt.Sprintf(
-				"namespace already exist, but not owned by profile creator %v", instance.Spec.Owner.Name))
+			return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, reason, msg, nil)
+		} else {
+			// Adopt the namespace approved by the admin, keeping its prior state to release it later
+			if err = adoptNamespace(foundNs, instance); err != nil {
+				IncRequestErrorCounter("error adopting namespace", SEVERITY_MAJOR)
+				logger.Error(err, "error adopting namespace")
+				return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, "NamespaceAdoptionFailed", err.Error(), err)
+			}
+			updateNamespaceMetadata(foundNs, template)
+			updateNamespaceLabels(foundNs)
+			for k, v := range r.Authorization.NamespaceLabels() {
+				foundNs.Labels[k] = v
+			}
+			logger.Info("Adopting Namespace: " + foundNs.Name)
+			if err = r.Update(ctx, foundNs); err != nil {
+				IncRequestErrorCounter("error adopting namespace", SEVERITY_MAJOR)
+				logger.Error(err, "error adopting namespace")
+				return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, "NamespaceAdoptionFailed", err.Error(), err)
+			}
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, "NamespaceAdopted",
+				"Took ownership of existing namespace %v", foundNs.Name)
 		}
 	}
+	if err = r.updateNetworkPolicies(profile); err != nil {
+		logger.Error(err, "error Updating NetworkPolicies", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating NetworkPolicies", SEVERITY_MAJOR)
+		return r.failAndReturn(ctx, instance, status, profilev1.NamespaceReady, "NetworkPolicyFailed", err.Error(), err)
+	}
+	status.setCondition(profilev1.NamespaceReady, true, "NamespaceReady", "")
 
-	// Update Istio AuthorizationPolicy
-	// Create Istio AuthorizationPolicy in target namespace, which will give ns owner permission to access services in ns.
-	if err = r.updateIstioAuthorizationPolicy(instance); err != nil {
-		logger.Error(err, "error Updating Istio AuthorizationPolicy permission", "namespace", instance.Name)
-		IncRequestErrorCounter("error updating Istio AuthorizationPolicy permission", SEVERITY_MAJOR)
-		return reconcile.Result{}, err
+	// Give ns owners permission to access services in ns, e.g. with an Istio AuthorizationPolicy.
+	if err = r.Authorization.UpdateOwners(r, instance); err != nil {
+		logger.Error(err, "error Updating owners authorization", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating owners authorization", SEVERITY_MAJOR)
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "AuthorizationPolicyFailed", err.Error(), err)
 	}
 
 	// Update service accounts
 	// Create service account "default-editor" in target namespace.
-	// "default-editor" would have kubeflowEdit permission: edit all resources in target namespace except rbac.
-	if err = r.updateServiceAccount(instance, DEFAULT_EDITOR, kubeflowEdit); err != nil {
+	// "default-editor" would have the ClusterRole of the edit role, kubeflow-edit by default: edit all resources
+	// in target namespace except rbac.
+	if err = r.updateServiceAccount(instance, DEFAULT_EDITOR, r.clusterRole(ROLEEDIT)); err != nil {
 		logger.Error(err, "error Updating ServiceAccount", "namespace", instance.Name, "name",
 			"defaultEditor")
 		IncRequestErrorCounter("error updating ServiceAccount", SEVERITY_MAJOR)
-		return reconcile.Result{}, err
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "ServiceAccountFailed", err.Error(), err)
 	}
 	// Create service account "default-viewer" in target namespace.
-	// "default-viewer" would have k8s default "view" permission: view all resources in target namespace.
-	if err = r.updateServiceAccount(instance, DEFAULT_VIEWER, kubeflowView); err != nil {
+	// "default-viewer" would have the ClusterRole of the view role, kubeflow-view by default: view all resources
+	// in target namespace.
+	if err = r.updateServiceAccount(instance, DEFAULT_VIEWER, r.clusterRole(ROLEVIEW)); err != nil {
 		logger.Error(err, "error Updating ServiceAccount", "namespace", instance.Name, "name",
 			"defaultViewer")
 		IncRequestErrorCounter("error updating ServiceAccount", SEVERITY_MAJOR)
-		return reconcile.Result{}, err
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "ServiceAccountFailed", err.Error(), err)
+	}
+	// Create the additional service accounts of the profile, bound to their ClusterRole.
+	if err = r.updateServiceAccounts(instance); err != nil {
+		logger.Error(err, "error Updating ServiceAccounts", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating ServiceAccounts", SEVERITY_MAJOR)
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "ServiceAccountFailed", err.Error(), err)
 	}
 
 	// TODO: add role for impersonate permission
@@ -222,139 +337,204 @@ func (r *ProfileReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error)
 	// When ClusterRole was referred by namespaced roleBinding, the result permission will be namespaced as well.
 	roleBinding := &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{USER: instance.Spec.Owner.Name, ROLE: ADMIN},
+			Annotations: map[string]string{USER: primaryOwner(instance).Name, ROLE: ADMIN},
 			Name:        "namespaceAdmin",
 			Namespace:   instance.Name,
 		},
-		// Use default ClusterRole 'admin' for profile/namespace owner
+		// Use the ClusterRole of the admin role for profile/namespace owner
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "ClusterRole",
-			Name:     kubeflowAdmin,
+			Name:     r.clusterRole(ROLEADMIN),
 		},
 		Subjects: []rbacv1.Subject{
-			instance.Spec.Owner,
+			primaryOwner(instance),
 		},
 	}
 	if err = r.updateRoleBinding(instance, roleBinding); err != nil {
 		logger.Error(err, "error Updating Owner Rolebinding", "namespace", instance.Name, "name",
 			"defaultEdittor")
 		IncRequestErrorCounter("error updating Owner Rolebinding", SEVERITY_MAJOR)
-		return reconcile.Result{}, err
-	}
-	// Create resource quota for target namespace if resources are specified in profile.
-	if len(instance.Spec.ResourceQuotaSpec.Hard) > 0 {
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "RoleBindingFailed", err.Error(), err)
+	}
+	// Same permission for the additional owners
+	if err = r.updateOwnerRoleBindings(instance); err != nil {
+		logger.Error(err, "error Updating Owners Rolebindings", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating Owners Rolebindings", SEVERITY_MAJOR)
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "RoleBindingFailed", err.Error(), err)
+	}
+	if err = r.updateContributors(instance); err != nil {
+		logger.Error(err, "error Updating Contributors", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating Contributors", SEVERITY_MAJOR)
+		return r.failAndReturn(ctx, instance, status, profilev1.RBACReady, "ContributorsFailed", err.Error(), err)
+	}
+	status.setCondition(profilev1.RBACReady, true, "RBACReady", "")
+	// Create resource quota for target namespace if resources are specified in profile or template.
+	quotaReason, quotaMessage := "QuotaReady", ""
+	if len(profile.Spec.ResourceQuotaSpec.Hard) > 0 {
 		resourceQuota := &corev1.ResourceQuota{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      KFQUOTA,
 				Namespace: instance.Name,
 			},
-			Spec: instance.Spec.ResourceQuotaSpec,
+			Spec: profile.Spec.ResourceQuotaSpec,
 		}
-		if err = r.updateResourceQuota(instance, resourceQuota); err != nil {
+		found, err := r.updateResourceQuota(instance, resourceQuota)
+		if err != nil {
 			logger.Error(err, "error Updating resource quota", "namespace", instance.Name)
 			IncRequestErrorCounter("error updating resource quota", SEVERITY_MAJOR)
-			return reconcile.Result{}, err
+			return r.failAndReturn(ctx, instance, status, profilev1.QuotaReady, "ResourceQuotaFailed", err.Error(), err)
 		}
+		status.setQuota(&found.Status)
 	} else {
-		logger.Info("No update on resource quota", "spec", instance.Spec.ResourceQuotaSpec.String())
+		logger.Info("No update on resource quota", "spec", profile.Spec.ResourceQuotaSpec.String())
+		quotaReason, quotaMessage = "NoQuota", "No resource quota is specified"
+		status.setQuota(nil)
+	}
+	// Without a LimitRange, pods which don't set requests are rejected by the quota
+	limitRangeSpec := profile.Spec.LimitRangeSpec
+	if limitRangeSpec == nil {
+		limitRangeSpec = quotaLimitRangeSpec(profile.Spec.ResourceQuotaSpec)
+	}
+	if err = r.updateLimitRange(instance, limitRangeSpec); err != nil {
+		logger.Error(err, "error Updating limit range", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating limit range", SEVERITY_MAJOR)
+		return r.failAndReturn(ctx, instance, status, profilev1.QuotaReady, "LimitRangeFailed", err.Error(), err)
+	}
+	status.setCondition(profilev1.QuotaReady, true, quotaReason, quotaMessage)
+	var podDefaults []profilev1.PodDefaultTemplate
+	if template != nil {
+		podDefaults = template.Spec.PodDefaults
+	}
+	if err = r.updatePodDefaults(instance, podDefaults); err != nil {
+		logger.Error(err, "error Updating PodDefaults", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating PodDefaults", SEVERITY_MAJOR)
+		return r.failAndReturn(ctx, instance, status, profilev1.TemplateReady, "PodDefaultsFailed", err.Error(), err)
+	}
+	if template != nil {
+		status.setCondition(profilev1.TemplateReady, true, "TemplateApplied", "")
+	} else {
+		status.setCondition(profilev1.TemplateReady, true, "NoTemplate", "")
 	}
-	if err := r.PatchDefaultPluginSpec(ctx, instance); err != nil {
+	if err := r.PatchDefaultPluginSpec(ctx, instance, template); err != nil {
 		IncRequestErrorCounter("error patching DefaultPluginSpec", SEVERITY_MAJOR)
 		logger.Error(err, "Failed patching DefaultPluginSpec", "namespace", instance.Name)
-		return reconcile.Result{}, err
-	}
-	if plugins, err := r.GetPluginSpec(instance); err == nil {
-		for _, plugin := range plugins {
-			if err2 := plugin.ApplyPlugin(r, instance); err2 != nil {
-				logger.Error(err2, "Failed applying plugin", "namespace", instance.Name)
-				IncRequestErrorCounter("error applying plugin", SEVERITY_MAJOR)
-				return reconcile.Result{}, err2
+		return r.failAndReturn(ctx, instance, status, profilev1.PluginsReady, "DefaultPluginsFailed", err.Error(), err)
+	}
+	// Merge again, as default plugins may have been added to the profile
+	profile = mergeTemplate(instance, template)
+	plugins, invalidPlugins := r.GetPluginSpec(profile)
+	var pluginErr error
+	status.plugins = []profilev1.PluginStatus{}
+	for _, invalid := range invalidPlugins {
+		status.setPlugin(invalid.Kind, invalid.Err)
+	}
+	// Revoke the plugins removed from the spec, or whose spec changed, before applying the new ones
+	remaining, revokeErr := r.revokePlugins(profile,
+		removedPlugins(instance.Status.AppliedPlugins, profile.Spec.Plugins), status)
+	// Apply all the plugins, so that each one has an up to date status
+	for _, plugin := range plugins {
+		start := time.Now()
+		err := plugin.ApplyPlugin(r, profile)
+		ObservePlugin(pluginKind(plugin), OPERATION_APPLY, start, err)
+		status.setPlugin(pluginKind(plugin), err)
+		if err != nil {
+			logger.Error(err, "Failed applying plugin", "namespace", instance.Name)
+			IncRequestErrorCounter("error applying plugin", SEVERITY_MAJOR)
+			if pluginErr == nil {
+				pluginErr = fmt.Errorf("plugin %v: %v", pluginKind(plugin), err)
 			}
 		}
 	}
+	// Plugins which failed may have been partly applied, they are recorded too
+	status.setAppliedPlugins(unionPlugins(validPluginSpecs(profile.Spec.Plugins), remaining))
+	if pluginErr != nil {
+		return r.failAndReturn(ctx, instance, status, profilev1.PluginsReady, "PluginFailed", pluginErr.Error(), pluginErr)
+	}
+	if revokeErr != nil {
+		return r.failAndReturn(ctx, instance, status, profilev1.PluginsReady, "PluginRevocationFailed",
+			revokeErr.Error(), revokeErr)
+	}
+	if len(invalidPlugins) > 0 {
+		// Retrying won't fix the spec, the profile is reconciled again when it's updated
+		IncRequestCounter("reject invalid plugin spec")
+		return r.failAndReturn(ctx, instance, status, profilev1.PluginsReady, "InvalidPluginSpec",
+			invalidPlugins[0].Error(), nil)
+	}
+	status.setCondition(profilev1.PluginsReady, true, "PluginsReady", "")
+	if err := r.writeStatus(ctx, instance, status); err != nil {
+		logger.Error(err, "error updating status", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating status", SEVERITY_MAJOR)
+		return reconcile.Result{}, err
+	}
 
-	// examine DeletionTimestamp to determine if object is under deletion
-	if instance.ObjectMeta.DeletionTimestamp.IsZero() {
-		// The object is not being deleted, so if it does not have our finalizer,
-		// then lets add the finalizer and update the object. This is equivalent
-		// registering our finalizer.
-		if !containsString(instance.ObjectMeta.Finalizers, PROFILEFINALIZER) {
-			instance.ObjectMeta.Finalizers = append(instance.ObjectMeta.Finalizers, PROFILEFINALIZER)
-			if err := r.Update(ctx, instance); err != nil {
-				logger.Error(err, "error updating finalizer", "namespace", instance.Name)
-				IncRequestErrorCounter("error updating finalizer", SEVERITY_MAJOR)
-				return ctrl.Result{}, err
-			}
-		}
-	} else {
-		// The object is being deleted
-		if containsString(instance.ObjectMeta.Finalizers, PROFILEFINALIZER) {
-			// our finalizer is present, so lets revoke all Plugins to clean up any external dependencies
-			if plugins, err := r.GetPluginSpec(instance); err == nil {
-				for _, plugin := range plugins {
-					if err := plugin.RevokePlugin(r, instance); err != nil {
-						logger.Error(err, "error revoking plugin", "namespace", instance.Name)
-						IncRequestErrorCounter("error revoking plugin", SEVERITY_MAJOR)
-						return reconcile.Result{}, err
-					}
-				}
-			}
-
-			// remove our finalizer from the list and update it.
-			instance.ObjectMeta.Finalizers = removeString(instance.ObjectMeta.Finalizers, PROFILEFINALIZER)
-			if err := r.Update(ctx, instance); err != nil {
-				logger.Error(err, "error removing finalizer", "namespace", instance.Name)
-				IncRequestErrorCounter("error removing finalizer", SEVERITY_MAJOR)
-				return ctrl.Result{}, err
-			}
+	// The object is not being deleted, so if it does not have our finalizer,
+	// then lets add the finalizer and update the object. This is equivalent
+	// registering our finalizer.
+	if !containsString(instance.ObjectMeta.Finalizers, PROFILEFINALIZER) {
+		instance.ObjectMeta.Finalizers = append(instance.ObjectMeta.Finalizers, PROFILEFINALIZER)
+		if err := r.Update(ctx, instance); err != nil {
+			logger.Error(err, "error updating finalizer", "namespace", instance.Name)
+			IncRequestErrorCounter("error updating finalizer", SEVERITY_MAJOR)
+			return ctrl.Result{}, err
 		}
 	}
 	IncRequestCounter("reconcile")
 	return ctrl.Result{}, nil
 }
 
-// appendErrorConditionAndReturn append failure status to profile CR and mark Reconcile done. If update condition failed, request will be requeued.
-func (r *ProfileReconciler) appendErrorConditionAndReturn(ctx context.Context, instance *profilev1.Profile,
-	message string) (ctrl.Result, error) {
-	instance.Status.Conditions = append(instance.Status.Conditions, profilev1.ProfileCondition{
-		Type:    profilev1.ProfileFailed,
-		Message: message,
-	})
-	if err := r.Update(ctx, instance); err != nil {
-		return reconcile.Result{}, err
-	}
-	return reconcile.Result{}, nil
-}
-
 func (r *ProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&profilev1.Profile{}).
-		Owns(&corev1.Namespace{}).
-		Owns(&istioSecurityClient.AuthorizationPolicy{}).
-		Owns(&corev1.ServiceAccount{}).
-		Owns(&rbacv1.RoleBinding{}).
-		Complete(r)
+		// Adopted namespaces aren't controlled by their profile, Owns() would miss them
+		Watches(&source.Kind{Type: &corev1.Namespace{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(profileForNamespace)}).
+		Watches(&source.Kind{Type: &profilev1.ProfileTemplate{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.profilesForTemplate)})
+	for _, obj := range r.managedTypes() {
+		builder = builder.Owns(obj)
+	}
+	return builder.Complete(r)
 }
 
 func (r *ProfileReconciler) getAuthorizationPolicy(profileIns *profilev1.Profile) istioSecurity.AuthorizationPolicy {
+	users := []string{}
+	groups := []string{}
+	for _, owner := range profileOwners(profileIns) {
+		if owner.Kind == rbacv1.GroupKind {
+			groups = append(groups, groupHeaderValues(owner.Name)...)
+		} else {
+			users = append(users, r.UserIdPrefix+owner.Name)
+		}
+	}
+	rules := []*istioSecurity.Rule{
+		{
+			When: []*istioSecurity.Condition{
+				{
+					// Namespace Owners can access all workloads in the
+					// namespace
+					Key:    fmt.Sprintf("request.headers[%v]", r.UserIdHeader),
+					Values: users,
+				},
+			},
+		},
+	}
+	if len(groups) > 0 && r.GroupsHeader != "" {
+		rules = append(rules, &istioSecurity.Rule{
+			When: []*istioSecurity.Condition{
+				{
+					// Members of the owner groups too
+					Key:    fmt.Sprintf("request.headers[%v]", r.GroupsHeader),
+					Values: groups,
+				},
+			},
+		})
+	}
 	return istioSecurity.AuthorizationPolicy{
 		Action: istioSecurity.AuthorizationPolicy_ALLOW,
 		// Empty selector == match all workloads in namespace
 		Selector: nil,
-		Rules: []*istioSecurity.Rule{
-			{
-				When: []*istioSecurity.Condition{
-					{
-						// Namespace Owner can access all workloads in the
-						// namespace
-						Key: fmt.Sprintf("request.headers[%v]", r.UserIdHeader),
-						Values: []string{
-							r.UserIdPrefix + profileIns.Spec.Owner.Name,
-						},
-					},
-				},
-			},
+		Rules: append(rules, []*istioSecurity.Rule{
 			{
 				When: []*istioSecurity.Condition{
 					{
@@ -365,25 +545,112 @@ func (r *ProfileReconciler) getAuthorizationPolicy(profileIns *profilev1.Profile
 					},
 				},
 			},
-		},
+		}...),
 	}
 }
 
+// groupHeaderValues returns the Istio header values matching a groups header
+// that is either the group itself, or a comma separated list starting or
+// ending with it. Istio only supports prefix and suffix matches, so a group
+// in the middle of a list isn't matched.
+func groupHeaderValues(group string) []string {
+	return []string{group, group + ",*", "*," + group}
+}
+
+// ownerRoleBindingName returns the name of the RoleBinding of an additional
+// owner, e.g. namespaceadmin-user-alice-example-com-1a2b3c4d. The hash of the
+// kind and name tells apart the owners whose names only differ by the
+// characters replaced with -, like a.b@x and a-b@x.
+func ownerRoleBindingName(owner rbacv1.Subject) string {
+	// Only keep lower case letters and numbers, replace other with -
+	reg := regexp.MustCompile("[^a-z0-9]+")
+	hash := sha256.Sum256([]byte(owner.Kind + "/" + owner.Name))
+	return reg.ReplaceAllString(strings.ToLower("namespaceAdmin-"+owner.Kind+"-"+owner.Name), "-") +
+		"-" + hex.EncodeToString(hash[:4])
+}
+
+// updateOwnerRoleBindings gives the owners of "profileIns" after the primary
+// one the same permission as it, and removes the RoleBindings of the owners
+// which aren't listed anymore.
+func (r *ProfileReconciler) updateOwnerRoleBindings(profileIns *profilev1.Profile) error {
+	desired := map[string]bool{}
+	owners := profileOwners(profileIns)
+	if len(owners) > 0 {
+		owners = owners[1:]
+	}
+	for _, owner := range owners {
+		roleBinding := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{USER: owner.Name, ROLE: ADMIN},
+				Labels:      map[string]string{OWNERBINDINGLABEL: "true"},
+				Name:        ownerRoleBindingName(owner),
+				Namespace:   profileIns.Name,
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     r.clusterRole(ROLEADMIN),
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					APIGroup: rbacv1.GroupName,
+					Kind:     owner.Kind,
+					Name:     owner.Name,
+				},
+			},
+		}
+		desired[roleBinding.Name] = true
+		if err := r.updateRoleBinding(profileIns, roleBinding); err != nil {
+			return err
+		}
+	}
+	return r.pruneRoleBindings(profileIns, OWNERBINDINGLABEL, desired)
+}
+
+// pruneRoleBindings deletes the RoleBindings with label "label" owned by
+// "profileIns" whose name isn't in "desired".
+func (r *ProfileReconciler) pruneRoleBindings(profileIns *profilev1.Profile, label string,
+	desired map[string]bool) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	found := &rbacv1.RoleBindingList{}
+	if err := r.List(context.TODO(), found, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{label: "true"}); err != nil {
+		return err
+	}
+	for i := range found.Items {
+		roleBinding := &found.Items[i]
+		if desired[roleBinding.Name] || !metav1.IsControlledBy(roleBinding, profileIns) {
+			continue
+		}
+		logger.Info("Deleting RoleBinding", "namespace", roleBinding.Namespace, "name", roleBinding.Name)
+		if err := r.Delete(context.TODO(), roleBinding); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 // updateIstioAuthorizationPolicy create or update Istio AuthorizationPolicy
 // resources in target namespace owned by "profileIns". The goal is to allow
 // service access for profile owner.
 func (r *ProfileReconciler) updateIstioAuthorizationPolicy(profileIns *profilev1.Profile) error {
-	logger := r.Log.WithValues("profile", profileIns.Name)
-
 	istioAuth := &istioSecurityClient.AuthorizationPolicy{
 		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{USER: profileIns.Spec.Owner.Name, ROLE: ADMIN},
+			Annotations: map[string]string{USER: primaryOwner(profileIns).Name, ROLE: ADMIN},
 			Name:        AUTHZPOLICYISTIO,
 			Namespace:   profileIns.Name,
 		},
 		Spec: r.getAuthorizationPolicy(profileIns),
 	}
+	return r.updateAuthorizationPolicy(profileIns, istioAuth)
+}
 
+// updateAuthorizationPolicy create or update Istio AuthorizationPolicy
+// "istioAuth" in target namespace owned by "profileIns". Policies which
+// aren't controlled by anything yet, like the ones kfam created, are adopted.
+func (r *ProfileReconciler) updateAuthorizationPolicy(profileIns *profilev1.Profile,
+	istioAuth *istioSecurityClient.AuthorizationPolicy) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
 	if err := controllerutil.SetControllerReference(profileIns, istioAuth, r.Scheme); err != nil {
 		return err
 	}
@@ -408,8 +675,16 @@ func (r *ProfileReconciler) updateIstioAuthorizationPolicy(profileIns *profilev1
 			return err
 		}
 	} else {
-		if !reflect.DeepEqual(istioAuth, foundAuthorizationPolicy) {
+		specChanged := !jsonEqual(&istioAuth.Spec, &foundAuthorizationPolicy.Spec)
+		if specChanged || metav1.GetControllerOf(foundAuthorizationPolicy) == nil ||
+			!hasLabels(foundAuthorizationPolicy, istioAuth.Labels) {
+			if specChanged && metav1.IsControlledBy(foundAuthorizationPolicy, profileIns) {
+				r.recordDrift(profileIns, "AuthorizationPolicy", foundAuthorizationPolicy, "spec")
+			}
 			foundAuthorizationPolicy.Spec = istioAuth.Spec
+			if err := adopt(profileIns, foundAuthorizationPolicy, istioAuth.Labels, r.Scheme); err != nil {
+				return err
+			}
 			logger.Info("Updating Istio AuthorizationPolicy", "namespace", istioAuth.ObjectMeta.Namespace,
 				"name", istioAuth.ObjectMeta.Name)
 			err = r.Update(context.TODO(), foundAuthorizationPolicy)
@@ -421,13 +696,13 @@ func (r *ProfileReconciler) updateIstioAuthorizationPolicy(profileIns *profilev1
 	return nil
 }
 
-// updateResourceQuota create or update ResourceQuota for target namespace
+// updateResourceQuota create or update ResourceQuota for target namespace, and returns it
 func (r *ProfileReconciler) updateResourceQuota(profileIns *profilev1.Profile,
-	resourceQuota *corev1.ResourceQuota) error {
+	resourceQuota *corev1.ResourceQuota) (*corev1.ResourceQuota, error) {
 	ctx := context.Background()
 	logger := r.Log.WithValues("profile", profileIns.Name)
 	if err := controllerutil.SetControllerReference(profileIns, resourceQuota, r.Scheme); err != nil {
-		return err
+		return nil, err
 	}
 	found := &corev1.ResourceQuota{}
 	err := r.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, found)
@@ -436,22 +711,25 @@ func (r *ProfileReconciler) updateResourceQuota(profileIns *profilev1.Profile,
 			logger.Info("Creating ResourceQuota", "namespace", resourceQuota.Namespace, "name", resourceQuota.Name)
 			err = r.Create(ctx, resourceQuota)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			return resourceQuota, nil
 		} else {
-			return err
+			return nil, err
 		}
 	} else {
-		if !(reflect.DeepEqual(resourceQuota.Spec, found.Spec)) {
+		// Semantic comparison, as quantities may differ in their format
+		if !apiequality.Semantic.DeepEqual(resourceQuota.Spec, found.Spec) {
+			r.recordDrift(profileIns, "ResourceQuota", found, "spec")
 			found.Spec = resourceQuota.Spec
 			logger.Info("Updating ResourceQuota", "namespace", resourceQuota.Namespace, "name", resourceQuota.Name)
 			err = r.Update(ctx, found)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
-	return nil
+	return found, nil
 }
 
 // updateServiceAccount create or update service account "saName" with role "ClusterRoleName" in target namespace owned by "profileIns"
@@ -503,10 +781,25 @@ func (r *ProfileReconciler) updateServiceAccount(profileIns *profilev1.Profile,
 	return r.updateRoleBinding(profileIns, roleBinding)
 }
 
+// normalizeSubjects returns the subjects with the defaults the API server
+// sets, so that they can be compared with the ones read back: the apiGroup of
+// the User and Group subjects is rbac.authorization.k8s.io.
+func normalizeSubjects(subjects []rbacv1.Subject) []rbacv1.Subject {
+	normalized := make([]rbacv1.Subject, len(subjects))
+	for i, subject := range subjects {
+		if subject.APIGroup == "" && (subject.Kind == rbacv1.UserKind || subject.Kind == rbacv1.GroupKind) {
+			subject.APIGroup = rbacv1.GroupName
+		}
+		normalized[i] = subject
+	}
+	return normalized
+}
+
 // updateRoleBinding create or update roleBinding "roleBinding" in target namespace owned by "profileIns"
 func (r *ProfileReconciler) updateRoleBinding(profileIns *profilev1.Profile,
 	roleBinding *rbacv1.RoleBinding) error {
 	logger := r.Log.WithValues("profile", profileIns.Name)
+	roleBinding.Subjects = normalizeSubjects(roleBinding.Subjects)
 	if err := controllerutil.SetControllerReference(profileIns, roleBinding, r.Scheme); err != nil {
 		return err
 	}
@@ -523,9 +816,32 @@ func (r *ProfileReconciler) updateRoleBinding(profileIns *profilev1.Profile,
 			return err
 		}
 	} else {
-		if !(reflect.DeepEqual(roleBinding.RoleRef, found.RoleRef) && reflect.DeepEqual(roleBinding.Subjects, found.Subjects)) {
-			found.RoleRef = roleBinding.RoleRef
+		drifted := []string{}
+		if !reflect.DeepEqual(roleBinding.RoleRef, found.RoleRef) {
+			drifted = append(drifted, "roleRef")
+		}
+		if !reflect.DeepEqual(roleBinding.Subjects, found.Subjects) {
+			drifted = append(drifted, "subjects")
+		}
+		if !hasLabels(found, roleBinding.Labels) {
+			drifted = append(drifted, "labels")
+		}
+		if len(drifted) > 0 || metav1.GetControllerOf(found) == nil {
+			if metav1.IsControlledBy(found, profileIns) {
+				r.recordDrift(profileIns, "RoleBinding", found, drifted...)
+			}
+			if !reflect.DeepEqual(roleBinding.RoleRef, found.RoleRef) {
+				// The roleRef of a RoleBinding can't be updated
+				logger.Info("Replacing RoleBinding", "namespace", roleBinding.Namespace, "name", roleBinding.Name)
+				if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+					return err
+				}
+				return r.Create(context.TODO(), roleBinding)
+			}
 			found.Subjects = roleBinding.Subjects
+			if err := adopt(profileIns, found, roleBinding.Labels, r.Scheme); err != nil {
+				return err
+			}
 			logger.Info("Updating RoleBinding", "namespace", roleBinding.Namespace, "name", roleBinding.Name)
 			err = r.Update(context.TODO(), found)
 			if err != nil {
@@ -536,47 +852,32 @@ func (r *ProfileReconciler) updateRoleBinding(profileIns *profilev1.Profile,
 	return nil
 }
 
-// GetPluginSpec will try to unmarshal the plugin spec inside profile for the specified plugin
-// Returns an error if the plugin isn't defined or if there is a problem
-func (r *ProfileReconciler) GetPluginSpec(profileIns *profilev1.Profile) ([]Plugin, error) {
+// GetPluginSpec decodes the plugins of the profile with the plugin registry.
+// The plugins which aren't registered or whose spec is invalid are returned
+// as errors, and skipped.
+func (r *ProfileReconciler) GetPluginSpec(profileIns *profilev1.Profile) ([]Plugin, []*PluginSpecError) {
 	logger := r.Log.WithValues("profile", profileIns.Name)
 	plugins := []Plugin{}
+	invalid := []*PluginSpecError{}
 	for _, p := range profileIns.Spec.Plugins {
-		var pluginIns Plugin
-		switch p.Kind {
-		case KIND_WORKLOAD_IDENTITY:
-			pluginIns = &GcpWorkloadIdentity{}
-		case KIND_AWS_IAM_FOR_SERVICE_ACCOUNT:
-			pluginIns = &AwsIAMForServiceAccount{}
-		default:
-			logger.Info("Plugin not recgonized: ", "Kind", p.Kind)
-			continue
-		}
-
-		// To deserialize it to a specific type we need to first serialize it to bytes
-		// and then unserialize it.
-		specBytes, err := yaml.Marshal(p.Spec)
-
+		pluginIns, err := DecodePlugin(p)
 		if err != nil {
-			logger.Info("Could not marshal plugin ", p.Kind, "; error: ", err)
-			return nil, err
-		}
-
-		err = yaml.Unmarshal(specBytes, pluginIns)
-		if err != nil {
-			logger.Info("Could not unmarshal plugin ", p.Kind, "; error: ", err)
-			return nil, err
+			logger.Info("Invalid plugin", "Kind", p.Kind, "error", err.Error())
+			invalid = append(invalid, err.(*PluginSpecError))
+			continue
 		}
 		plugins = append(plugins, pluginIns)
 	}
-	return plugins, nil
+	return plugins, invalid
 }
 
-// PatchDefaultPluginSpec patch default plugins to profile CR instance if user doesn't specify plugin of same kind in CR.
-func (r *ProfileReconciler) PatchDefaultPluginSpec(ctx context.Context, profileIns *profilev1.Profile) error {
+// PatchDefaultPluginSpec patch default plugins to profile CR instance if user doesn't specify plugin of same kind in CR
+// or its template.
+func (r *ProfileReconciler) PatchDefaultPluginSpec(ctx context.Context, profileIns *profilev1.Profile,
+	template *profilev1.ProfileTemplate) error {
 	// read existing plugins into map
 	plugins := make(map[string]profilev1.Plugin)
-	for _, p := range profileIns.Spec.Plugins {
+	for _, p := range mergeTemplate(profileIns, template).Spec.Plugins {
 		plugins[p.Kind] = p
 	}
 	// Patch default plugins if same kind doesn't exist yet.
@@ -598,6 +899,37 @@ func (r *ProfileReconciler) PatchDefaultPluginSpec(ctx context.Context, profileI
 	return nil
 }
 
+// adopt sets "profileIns" as the controller of "obj" if it has none, and adds
+// the labels to it.
+func adopt(profileIns *profilev1.Profile, obj metav1.Object, labels map[string]string, scheme *runtime.Scheme) error {
+	if metav1.GetControllerOf(obj) == nil {
+		if err := controllerutil.SetControllerReference(profileIns, obj, scheme); err != nil {
+			return err
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	merged := obj.GetLabels()
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	obj.SetLabels(merged)
+	return nil
+}
+
+func hasLabels(obj metav1.Object, labels map[string]string) bool {
+	for k, v := range labels {
+		if obj.GetLabels()[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
 func containsString(slice []string, s string) bool {
 	for _, item := range slice {
 		if item == s {
@@ -4,8 +4,11 @@ import (
 	"reflect"
 	"testing"
 
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 func TestUpdateNamespaceLabels(t *testing.T) {
@@ -60,3 +63,238 @@ func TestUpdateNamespaceLabels(t *testing.T) {
 		}
 	}
 }
+
+func TestOwnerRoleBindingName(t *testing.T) {
+	tests := []struct {
+		owner    rbacv1.Subject
+		expected string
+	}{
+		{rbacv1.Subject{Kind: rbacv1.UserKind, Name: "Alice@example.com"}, "namespaceadmin-user-alice-example-com-67cbcf63"},
+		{rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "team-a"}, "namespaceadmin-group-team-a-d024d42b"},
+	}
+	for _, test := range tests {
+		if name := ownerRoleBindingName(test.owner); name != test.expected {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.expected, name)
+		}
+	}
+	// Names only differing by the replaced characters get different bindings
+	first := ownerRoleBindingName(rbacv1.Subject{Kind: rbacv1.UserKind, Name: "a.b@x"})
+	second := ownerRoleBindingName(rbacv1.Subject{Kind: rbacv1.UserKind, Name: "a-b@x"})
+	if first == second {
+		t.Errorf("Expect:\ndifferent names; Output:\n%v, %v", first, second)
+	}
+}
+
+func TestProfileOwners(t *testing.T) {
+	alice := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"}
+	team := rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "team-a"}
+	tests := []struct {
+		spec     profilev1.ProfileSpec
+		expected []rbacv1.Subject
+	}{
+		{profilev1.ProfileSpec{Owner: alice}, []rbacv1.Subject{alice}},
+		{profilev1.ProfileSpec{Owners: []rbacv1.Subject{team, alice}}, []rbacv1.Subject{team, alice}},
+		// The deprecated owner comes first, and isn't repeated
+		{profilev1.ProfileSpec{Owner: alice, Owners: []rbacv1.Subject{team, alice}}, []rbacv1.Subject{alice, team}},
+		{profilev1.ProfileSpec{}, []rbacv1.Subject{}},
+	}
+	for _, test := range tests {
+		owners := profileOwners(&profilev1.Profile{Spec: test.spec})
+		if !reflect.DeepEqual(owners, test.expected) {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.expected, owners)
+		}
+	}
+}
+
+func TestGetAuthorizationPolicyOwners(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "team"},
+		Spec: profilev1.ProfileSpec{
+			Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+			Owners: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, Name: "bob@example.com"},
+				{Kind: rbacv1.GroupKind, Name: "team-a"},
+			},
+		},
+	}
+	tests := []struct {
+		groupsHeader  string
+		expectedRules int
+	}{
+		{"", 2},
+		{"kubeflow-groups", 3},
+	}
+	for _, test := range tests {
+		r := &ProfileReconciler{UserIdHeader: "kubeflow-userid", UserIdPrefix: "", GroupsHeader: test.groupsHeader}
+		policy := r.getAuthorizationPolicy(profile)
+		if len(policy.Rules) != test.expectedRules {
+			t.Fatalf("Expect %v rules; Output: %v", test.expectedRules, policy.Rules)
+		}
+		users := policy.Rules[0].When[0].Values
+		if !reflect.DeepEqual(users, []string{"alice@example.com", "bob@example.com"}) {
+			t.Errorf("Unexpected users: %v", users)
+		}
+		if test.groupsHeader == "" {
+			continue
+		}
+		groups := policy.Rules[1].When[0]
+		if groups.Key != "request.headers[kubeflow-groups]" ||
+			!reflect.DeepEqual(groups.Values, []string{"team-a", "team-a,*", "*,team-a"}) {
+			t.Errorf("Unexpected groups condition: %v", groups)
+		}
+	}
+}
+
+func TestContributorBindingName(t *testing.T) {
+	// Same names as the bindings created by kfam, so they get adopted
+	tests := []struct {
+		contributor profilev1.Contributor
+		expected    string
+	}{
+		{
+			profilev1.Contributor{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "lalith.vaka@zq.msds.kp.org"}, Role: "edit"},
+			"user-lalith-vaka-zq-msds-kp-org-clusterrole-edit",
+		},
+		{
+			profilev1.Contributor{Subject: rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "Team_A"}, Role: "view"},
+			"group-team-a-clusterrole-view",
+		},
+	}
+	for _, test := range tests {
+		if name := contributorBindingName(test.contributor); name != test.expected {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.expected, name)
+		}
+	}
+}
+
+func TestValidateAccess(t *testing.T) {
+	tests := []struct {
+		spec     profilev1.ProfileSpec
+		hasError bool
+	}{
+		{
+			spec: profilev1.ProfileSpec{
+				Owners: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "team-a"}},
+				Contributors: []profilev1.Contributor{
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "view"},
+				},
+			},
+			hasError: false,
+		},
+		{
+			spec: profilev1.ProfileSpec{
+				Owners: []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "default"}},
+			},
+			hasError: true,
+		},
+		{
+			spec:     profilev1.ProfileSpec{},
+			hasError: true,
+		},
+		{
+			spec: profilev1.ProfileSpec{
+				Owners: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice@example.com"}},
+				Contributors: []profilev1.Contributor{
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "owner"},
+				},
+			},
+			hasError: true,
+		},
+		// Same contributor with different roles
+		{
+			spec: profilev1.ProfileSpec{
+				Owners: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice@example.com"}},
+				Contributors: []profilev1.Contributor{
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "view"},
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "edit"},
+				},
+			},
+			hasError: false,
+		},
+		// Same contributor listed twice
+		{
+			spec: profilev1.ProfileSpec{
+				Owners: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice@example.com"}},
+				Contributors: []profilev1.Contributor{
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "view"},
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "view"},
+				},
+			},
+			hasError: true,
+		},
+		// Different contributors whose bindings have the same name
+		{
+			spec: profilev1.ProfileSpec{
+				Owners: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice@example.com"}},
+				Contributors: []profilev1.Contributor{
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "a.b@example.com"}, Role: "view"},
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "a-b@example.com"}, Role: "view"},
+				},
+			},
+			hasError: true,
+		},
+		// Same name, different kinds
+		{
+			spec: profilev1.ProfileSpec{
+				Owners: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice@example.com"}},
+				Contributors: []profilev1.Contributor{
+					{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "team-a"}, Role: "view"},
+					{Subject: rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "team-a"}, Role: "view"},
+				},
+			},
+			hasError: false,
+		},
+	}
+	for _, test := range tests {
+		err := validateAccess(&profilev1.Profile{Spec: test.spec}, DefaultRoleCatalog())
+		if (err != nil) != test.hasError {
+			t.Errorf("Expect error: %v; Output: %v", test.hasError, err)
+		}
+	}
+}
+
+func TestNormalizeSubjects(t *testing.T) {
+	subjects := []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, Name: "alice@example.com"},
+		{Kind: rbacv1.GroupKind, Name: "team-a", APIGroup: rbacv1.GroupName},
+		{Kind: rbacv1.ServiceAccountKind, Name: DEFAULT_EDITOR, Namespace: "alice"},
+	}
+	expected := []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, Name: "alice@example.com", APIGroup: rbacv1.GroupName},
+		{Kind: rbacv1.GroupKind, Name: "team-a", APIGroup: rbacv1.GroupName},
+		{Kind: rbacv1.ServiceAccountKind, Name: DEFAULT_EDITOR, Namespace: "alice"},
+	}
+	if normalized := normalizeSubjects(subjects); !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expected, normalized)
+	}
+	if subjects[0].APIGroup != "" {
+		t.Errorf("Expect:\nsubjects unchanged; Output:\n%v", subjects)
+	}
+}
+
+func TestUpdateRoleBindingDefaultedSubjects(t *testing.T) {
+	r, profile := newDeletionTest(t, profilev1.DeletionPolicyDelete, profileNamespace())
+	roleBinding := func() *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "namespaceAdmin", Namespace: "alice"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "kubeflow-admin"},
+			Subjects:   []rbacv1.Subject{profile.Spec.Owner},
+		}
+	}
+	// The stored subjects are defaulted by the API server
+	stored := roleBinding()
+	stored.Subjects[0].APIGroup = rbacv1.GroupName
+	if err := r.updateRoleBinding(profile, stored); err != nil {
+		t.Fatal(err)
+	}
+	recorder := r.Recorder.(*record.FakeRecorder)
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+	if err := r.updateRoleBinding(profile, roleBinding()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Events) > 0 {
+		t.Errorf("Expect:\nno drift; Output:\n%v", <-recorder.Events)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const KFLIMITRANGE = "kf-limit-range"
+
+// Default requests and limits of the containers in namespaces whose quota
+// constrains them, so that pods which don't set them aren't rejected.
+var (
+	defaultContainerRequests = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("256Mi"),
+	}
+	defaultContainerLimits = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("1"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}
+)
+
+// ParseQuotaThresholds parses a comma separated list of usage ratios between
+// 0 and 1, e.g. "0.8,0.95".
+func ParseQuotaThresholds(value string) ([]float64, error) {
+	thresholds := []float64{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota threshold %q: %v", field, err)
+		}
+		if threshold <= 0 || threshold > 1 {
+			return nil, fmt.Errorf("invalid quota threshold %q: must be in (0, 1]", field)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
+
+// quotaCrossing is a threshold crossed by the usage of a resource.
+type quotaCrossing struct {
+	resource  corev1.ResourceName
+	threshold float64
+	// Whether the usage went above the threshold, or back below it
+	above bool
+	used  resource.Quantity
+	hard  resource.Quantity
+}
+
+func quotaRatio(quota *corev1.ResourceQuotaStatus, name corev1.ResourceName) float64 {
+	if quota == nil {
+		return 0
+	}
+	hard, ok := quota.Hard[name]
+	if !ok || hard.MilliValue() == 0 {
+		return 0
+	}
+	used := quota.Used[name]
+	return float64(used.MilliValue()) / float64(hard.MilliValue())
+}
+
+// quotaCrossings returns the thresholds crossed by the usage of the resources
+// of the quota between "old" and "new".
+func quotaCrossings(old, new *corev1.ResourceQuotaStatus, thresholds []float64) []quotaCrossing {
+	if new == nil {
+		return nil
+	}
+	names := []string{}
+	for name := range new.Hard {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	crossings := []quotaCrossing{}
+	for _, name := range names {
+		resourceName := corev1.ResourceName(name)
+		oldRatio, newRatio := quotaRatio(old, resourceName), quotaRatio(new, resourceName)
+		for _, threshold := range thresholds {
+			above := oldRatio < threshold && newRatio >= threshold
+			below := oldRatio >= threshold && newRatio < threshold
+			if above || below {
+				crossings = append(crossings, quotaCrossing{
+					resource:  resourceName,
+					threshold: threshold,
+					above:     above,
+					used:      new.Used[resourceName],
+					hard:      new.Hard[resourceName],
+				})
+			}
+		}
+	}
+	return crossings
+}
+
+// recordQuotaEvents emits an Event on "profileIns" for each threshold crossed
+// by the usage of its quota.
+func (r *ProfileReconciler) recordQuotaEvents(profileIns *profilev1.Profile, old, new *corev1.ResourceQuotaStatus) {
+	for _, crossing := range quotaCrossings(old, new, r.QuotaThresholds) {
+		if crossing.above {
+			r.Recorder.Eventf(profileIns, corev1.EventTypeWarning, "QuotaThresholdExceeded",
+				"Usage of %v is %v of %v, above %v%% of the quota", crossing.resource, crossing.used.String(),
+				crossing.hard.String(), crossing.threshold*100)
+		} else {
+			r.Recorder.Eventf(profileIns, corev1.EventTypeNormal, "QuotaThresholdCleared",
+				"Usage of %v is %v of %v, below %v%% of the quota", crossing.resource, crossing.used.String(),
+				crossing.hard.String(), crossing.threshold*100)
+		}
+	}
+}
+
+// quotaLimitRangeSpec returns a LimitRangeSpec with default requests and
+// limits for the resources constrained by "quota", or nil if there are none.
+func quotaLimitRangeSpec(quota corev1.ResourceQuotaSpec) *corev1.LimitRangeSpec {
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		_, ok := quota.Hard[name]
+		_, requestOk := quota.Hard[corev1.ResourceName("requests."+name)]
+		_, limitOk := quota.Hard[corev1.ResourceName("limits."+name)]
+		if ok || requestOk || limitOk {
+			requests[name] = defaultContainerRequests[name]
+		}
+		if limitOk {
+			limits[name] = defaultContainerLimits[name]
+		}
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+	item := corev1.LimitRangeItem{
+		Type:           corev1.LimitTypeContainer,
+		DefaultRequest: requests,
+	}
+	if len(limits) > 0 {
+		item.Default = limits
+	}
+	return &corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{item}}
+}
+
+// updateLimitRange create or update the LimitRange of the namespace of
+// "profileIns", or deletes it if "spec" is nil.
+func (r *ProfileReconciler) updateLimitRange(profileIns *profilev1.Profile, spec *corev1.LimitRangeSpec) error {
+	ctx := context.Background()
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	found := &corev1.LimitRange{}
+	err := r.Get(ctx, types.NamespacedName{Name: KFLIMITRANGE, Namespace: profileIns.Name}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if spec == nil {
+		if err == nil && metav1.IsControlledBy(found, profileIns) {
+			logger.Info("Deleting LimitRange", "namespace", found.Namespace, "name", found.Name)
+			if err := r.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	limitRange := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      KFLIMITRANGE,
+			Namespace: profileIns.Name,
+		},
+		Spec: *spec,
+	}
+	if err := controllerutil.SetControllerReference(profileIns, limitRange, r.Scheme); err != nil {
+		return err
+	}
+	if errors.IsNotFound(err) {
+		logger.Info("Creating LimitRange", "namespace", limitRange.Namespace, "name", limitRange.Name)
+		return r.Create(ctx, limitRange)
+	}
+	// Semantic comparison, as quantities may differ in their format
+	if !apiequality.Semantic.DeepEqual(limitRange.Spec, found.Spec) {
+		r.recordDrift(profileIns, "LimitRange", found, "spec")
+		found.Spec = limitRange.Spec
+		logger.Info("Updating LimitRange", "namespace", found.Namespace, "name", found.Name)
+		return r.Update(ctx, found)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestQuotaLimitRangeSpec(t *testing.T) {
+	tests := []struct {
+		hard     corev1.ResourceList
+		expected *corev1.LimitRangeSpec
+	}{
+		{
+			hard:     corev1.ResourceList{"pods": resource.MustParse("10")},
+			expected: nil,
+		},
+		{
+			hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")},
+			expected: &corev1.LimitRangeSpec{
+				Limits: []corev1.LimitRangeItem{
+					{
+						Type:           corev1.LimitTypeContainer,
+						DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					},
+				},
+			},
+		},
+		{
+			hard: corev1.ResourceList{
+				corev1.ResourceRequestsMemory: resource.MustParse("16Gi"),
+				corev1.ResourceLimitsCPU:      resource.MustParse("16"),
+			},
+			expected: &corev1.LimitRangeSpec{
+				Limits: []corev1.LimitRangeItem{
+					{
+						Type: corev1.LimitTypeContainer,
+						DefaultRequest: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("100m"),
+							corev1.ResourceMemory: resource.MustParse("256Mi"),
+						},
+						Default: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		output := quotaLimitRangeSpec(corev1.ResourceQuotaSpec{Hard: test.hard})
+		if !reflect.DeepEqual(output, test.expected) {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.expected, output)
+		}
+	}
+}
+
+func TestParseQuotaThresholds(t *testing.T) {
+	output, err := ParseQuotaThresholds("0.95, 0.8")
+	if err != nil || !reflect.DeepEqual(output, []float64{0.8, 0.95}) {
+		t.Errorf("Expect:\n%v; Output:\n%v, %v", []float64{0.8, 0.95}, output, err)
+	}
+	for _, value := range []string{"80", "0", "high"} {
+		if _, err := ParseQuotaThresholds(value); err == nil {
+			t.Errorf("Expect:\nerror for %q; Output:\nnil", value)
+		}
+	}
+}
+
+func TestQuotaCrossings(t *testing.T) {
+	quota := func(used string) *corev1.ResourceQuotaStatus {
+		return &corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10")},
+			Used: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(used)},
+		}
+	}
+	thresholds := []float64{0.8, 0.95}
+	tests := []struct {
+		old      *corev1.ResourceQuotaStatus
+		new      *corev1.ResourceQuotaStatus
+		expected []string
+	}{
+		{nil, quota("1"), []string{}},
+		{nil, quota("8500m"), []string{"cpu above 0.8"}},
+		{quota("1"), quota("10"), []string{"cpu above 0.8", "cpu above 0.95"}},
+		{quota("9"), quota("9500m"), []string{"cpu above 0.95"}},
+		{quota("9600m"), quota("9"), []string{"cpu below 0.95"}},
+		{quota("9"), quota("8"), []string{}},
+		{quota("9"), nil, []string{}},
+	}
+	for _, test := range tests {
+		output := []string{}
+		for _, c := range quotaCrossings(test.old, test.new, thresholds) {
+			direction := "below"
+			if c.above {
+				direction = "above"
+			}
+			output = append(output, fmt.Sprintf("%v %v %v", c.resource, direction, c.threshold))
+		}
+		if !reflect.DeepEqual(output, test.expected) {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.expected, output)
+		}
+	}
+}
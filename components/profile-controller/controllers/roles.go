@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/kubeflow/kubeflow/components/common/roles"
+)
+
+// Roles of the catalog the controller itself binds: owners are admins,
+// "default-editor" and "default-viewer" are editors and viewers.
+const (
+	ROLEADMIN = roles.ADMIN
+	ROLEEDIT  = roles.EDIT
+	ROLEVIEW  = roles.VIEW
+)
+
+// Role maps the name of a role, used in spec.contributors and by kfam, to
+// the ClusterRole its RoleBindings refer to.
+type Role = roles.Role
+
+// RoleCatalog is the list of the roles of the profiles, read from the file
+// given with -role-catalog. It's shared with kfam, which validates it the
+// same way.
+type RoleCatalog = roles.Catalog
+
+// DefaultRoleCatalog returns the roles used when no catalog is given.
+func DefaultRoleCatalog() *RoleCatalog {
+	return roles.DefaultCatalog()
+}
+
+// LoadRoleCatalog reads and validates the YAML or JSON catalog at "path", or
+// returns the default catalog if "path" is empty.
+func LoadRoleCatalog(path string) (*RoleCatalog, error) {
+	return roles.LoadCatalog(path)
+}
+
+// roles returns the role catalog of the controller, the default one if none
+// was set.
+func (r *ProfileReconciler) roles() *RoleCatalog {
+	if r.Roles == nil {
+		return DefaultRoleCatalog()
+	}
+	return r.Roles
+}
+
+// clusterRole returns the ClusterRole of one of the roles a valid catalog
+// declares.
+func (r *ProfileReconciler) clusterRole(name string) string {
+	clusterRole, _ := r.roles().ClusterRole(name)
+	return clusterRole
+}
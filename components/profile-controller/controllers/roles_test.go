@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kubeflow/kubeflow/components/common/roles"
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestParseRoleCatalog(t *testing.T) {
+	tests := []struct {
+		catalog string
+		err     string
+	}{
+		{`
+roles:
+- name: admin
+  clusterRole: kubeflow-admin
+- name: edit
+  clusterRole: kubeflow-edit
+- name: view
+  clusterRole: kubeflow-view
+- name: pipeline-runner
+  clusterRole: kubeflow-pipeline-runner
+`, ""},
+		{`{"roles": [{"name": "admin", "clusterRole": "admin"}, {"name": "edit", "clusterRole": "edit"},
+			{"name": "view", "clusterRole": "view"}]}`, ""},
+		{`
+roles:
+- name: admin
+  clusterRole: kubeflow-admin
+- name: edit
+  clusterRole: kubeflow-edit
+`, "role view is required"},
+		{`
+roles:
+- name: Pipeline_Runner
+  clusterRole: kubeflow-pipeline-runner
+`, "is invalid"},
+		{`
+roles:
+- name: runner
+  clusterRole: ""
+`, "is invalid"},
+		{`
+roles:
+- name: admin
+  clusterRole: kubeflow-admin
+- name: admin
+  clusterRole: kubeflow-edit
+`, "declared twice"},
+		{`
+roles:
+- name: edit
+  clusterRole: kubeflow-edit
+- name: runner
+  clusterRole: kubeflow-edit
+`, "used by several roles"},
+	}
+	for _, test := range tests {
+		_, err := roles.ParseCatalog(strings.NewReader(test.catalog))
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.err, err)
+		}
+	}
+	if err := DefaultRoleCatalog().Validate(); err != nil {
+		t.Errorf("Expect:\nnil; Output:\n%v", err)
+	}
+}
+
+func TestRoleCatalogRoles(t *testing.T) {
+	catalog := DefaultRoleCatalog()
+	catalog.Roles = append(catalog.Roles, Role{Name: "pipeline-runner", ClusterRole: "kubeflow-pipeline-runner"})
+	if clusterRole, ok := catalog.ClusterRole("pipeline-runner"); !ok || clusterRole != "kubeflow-pipeline-runner" {
+		t.Errorf("Expect:\nkubeflow-pipeline-runner; Output:\n%v", clusterRole)
+	}
+	if _, ok := catalog.ClusterRole("owner"); ok {
+		t.Errorf("Expect:\nunknown role owner; Output:\n%v", ok)
+	}
+	expected := []string{"kubeflow-admin", "kubeflow-edit", "kubeflow-pipeline-runner", "kubeflow-view"}
+	if !reflect.DeepEqual(catalog.ClusterRoles(), expected) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expected, catalog.ClusterRoles())
+	}
+	if role, ok := catalog.RoleOf("kubeflow-pipeline-runner"); !ok || role != "pipeline-runner" {
+		t.Errorf("Expect:\npipeline-runner; Output:\n%v", role)
+	}
+
+	// Contributors and service accounts may use the roles of the catalog
+	profile := &profilev1.Profile{Spec: profilev1.ProfileSpec{
+		Owners: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice@example.com"}},
+		Contributors: []profilev1.Contributor{
+			{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "pipeline-runner"},
+		},
+		ServiceAccounts: []profilev1.ProfileServiceAccount{
+			{Name: "runner", ClusterRole: "kubeflow-pipeline-runner"},
+		},
+	}}
+	if err := validateAccess(profile, catalog); err != nil {
+		t.Errorf("Expect:\nnil; Output:\n%v", err)
+	}
+	if err := validateServiceAccounts(profile, catalog); err != nil {
+		t.Errorf("Expect:\nnil; Output:\n%v", err)
+	}
+	if err := validateAccess(profile, DefaultRoleCatalog()); err == nil {
+		t.Errorf("Expect:\nunknown role pipeline-runner; Output:\n%v", err)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Label of the ServiceAccounts and RoleBindings generated for
+// spec.serviceAccounts, used to prune the ones of removed service accounts.
+const SERVICEACCOUNTLABEL = "profiles.kubeflow.org/service-account"
+
+// reservedServiceAccounts can't be declared in spec.serviceAccounts, they
+// are created by Kubernetes or by the controller itself.
+var reservedServiceAccounts = []string{"default", DEFAULT_EDITOR, DEFAULT_VIEWER}
+
+// validateServiceAccounts checks the names of spec.serviceAccounts, and that
+// their ClusterRoles are the ones of roles of the catalog.
+func validateServiceAccounts(profileIns *profilev1.Profile, roles *RoleCatalog) error {
+	names := map[string]bool{}
+	for _, sa := range profileIns.Spec.ServiceAccounts {
+		if err := validateServiceAccountName(sa.Name); err != nil {
+			return err
+		}
+		if containsString(reservedServiceAccounts, sa.Name) {
+			return fmt.Errorf("service account %v is reserved", sa.Name)
+		}
+		if names[sa.Name] {
+			return fmt.Errorf("service account %v is declared twice", sa.Name)
+		}
+		names[sa.Name] = true
+		if !containsString(roles.ClusterRoles(), sa.ClusterRole) {
+			return fmt.Errorf("service account %v has ClusterRole %v, only %v are allowed", sa.Name, sa.ClusterRole,
+				strings.Join(roles.ClusterRoles(), ", "))
+		}
+	}
+	return nil
+}
+
+// validateServiceAccountName checks that "name" is a valid ServiceAccount name.
+func validateServiceAccountName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("service account %q is invalid: %v", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// pluginServiceAccounts returns the service accounts a plugin targets:
+// "names" when set, "defaults" otherwise.
+func pluginServiceAccounts(names []string, defaults ...string) []string {
+	if len(names) > 0 {
+		return names
+	}
+	return defaults
+}
+
+// validatePluginServiceAccounts checks the service accounts listed in the
+// spec of a plugin.
+func validatePluginServiceAccounts(names []string) error {
+	for _, name := range names {
+		if err := validateServiceAccountName(name); err != nil {
+			return fmt.Errorf("serviceAccounts: %v", err)
+		}
+	}
+	return nil
+}
+
+// serviceAccountRoleBindingName returns the name of the RoleBinding of a
+// service account of spec.serviceAccounts, which can't clash with the ones
+// of the owners and contributors.
+func serviceAccountRoleBindingName(sa profilev1.ProfileServiceAccount) string {
+	return "serviceaccount-" + sa.Name
+}
+
+// updateServiceAccounts create or update the service accounts of
+// spec.serviceAccounts and their RoleBindings, and delete the ones which
+// aren't listed anymore.
+func (r *ProfileReconciler) updateServiceAccounts(profileIns *profilev1.Profile) error {
+	labels := map[string]string{SERVICEACCOUNTLABEL: "true"}
+	desired := map[string]bool{}
+	desiredBindings := map[string]bool{}
+	for _, sa := range profileIns.Spec.ServiceAccounts {
+		serviceAccount := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:    labels,
+				Name:      sa.Name,
+				Namespace: profileIns.Name,
+			},
+		}
+		if err := r.updateLabeledServiceAccount(profileIns, serviceAccount); err != nil {
+			return err
+		}
+		roleBinding := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:    labels,
+				Name:      serviceAccountRoleBindingName(sa),
+				Namespace: profileIns.Name,
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     sa.ClusterRole,
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					Kind:      rbacv1.ServiceAccountKind,
+					Name:      sa.Name,
+					Namespace: profileIns.Name,
+				},
+			},
+		}
+		if err := r.updateRoleBinding(profileIns, roleBinding); err != nil {
+			return err
+		}
+		desired[sa.Name] = true
+		desiredBindings[roleBinding.Name] = true
+	}
+	if err := r.pruneRoleBindings(profileIns, SERVICEACCOUNTLABEL, desiredBindings); err != nil {
+		return err
+	}
+	return r.pruneServiceAccounts(profileIns, desired)
+}
+
+// updateLabeledServiceAccount creates "serviceAccount", or adds its labels
+// to the existing one. Service accounts which exist without being
+// controlled by "profileIns" are never taken over, as they would be deleted
+// with it.
+func (r *ProfileReconciler) updateLabeledServiceAccount(profileIns *profilev1.Profile,
+	serviceAccount *corev1.ServiceAccount) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	if err := controllerutil.SetControllerReference(profileIns, serviceAccount, r.Scheme); err != nil {
+		return err
+	}
+	found := &corev1.ServiceAccount{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: serviceAccount.Name, Namespace: serviceAccount.Namespace}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("Creating ServiceAccount", "namespace", serviceAccount.Namespace, "name", serviceAccount.Name)
+			return r.Create(context.TODO(), serviceAccount)
+		}
+		return err
+	}
+	if !metav1.IsControlledBy(found, profileIns) {
+		return fmt.Errorf("service account %v already exists and isn't managed by the profile", found.Name)
+	}
+	if hasLabels(found, serviceAccount.Labels) {
+		return nil
+	}
+	if err := adopt(profileIns, found, serviceAccount.Labels, r.Scheme); err != nil {
+		return err
+	}
+	logger.Info("Updating ServiceAccount", "namespace", found.Namespace, "name", found.Name)
+	return r.Update(context.TODO(), found)
+}
+
+// pruneServiceAccounts deletes the service accounts generated for
+// spec.serviceAccounts whose name isn't in "desired".
+func (r *ProfileReconciler) pruneServiceAccounts(profileIns *profilev1.Profile, desired map[string]bool) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	found := &corev1.ServiceAccountList{}
+	if err := r.List(context.TODO(), found, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{SERVICEACCOUNTLABEL: "true"}); err != nil {
+		return err
+	}
+	for i := range found.Items {
+		serviceAccount := &found.Items[i]
+		if desired[serviceAccount.Name] || !metav1.IsControlledBy(serviceAccount, profileIns) {
+			continue
+		}
+		logger.Info("Deleting ServiceAccount", "namespace", serviceAccount.Namespace, "name", serviceAccount.Name)
+		if err := r.Delete(context.TODO(), serviceAccount); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
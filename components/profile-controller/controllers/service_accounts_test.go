@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateServiceAccounts(t *testing.T) {
+	tests := []struct {
+		serviceAccounts []profilev1.ProfileServiceAccount
+		err             string
+	}{
+		{nil, ""},
+		{[]profilev1.ProfileServiceAccount{{Name: "pipeline-runner", ClusterRole: "kubeflow-edit"}}, ""},
+		{[]profilev1.ProfileServiceAccount{{Name: "Pipeline_Runner", ClusterRole: "kubeflow-edit"}}, "is invalid"},
+		{[]profilev1.ProfileServiceAccount{{Name: DEFAULT_EDITOR, ClusterRole: "kubeflow-edit"}}, "is reserved"},
+		{[]profilev1.ProfileServiceAccount{{Name: "default", ClusterRole: "kubeflow-view"}}, "is reserved"},
+		{[]profilev1.ProfileServiceAccount{
+			{Name: "pipeline-runner", ClusterRole: "kubeflow-edit"},
+			{Name: "pipeline-runner", ClusterRole: "kubeflow-view"},
+		}, "declared twice"},
+		{[]profilev1.ProfileServiceAccount{{Name: "pipeline-runner", ClusterRole: "cluster-admin"}}, "only kubeflow-admin"},
+	}
+	for _, test := range tests {
+		profile := &profilev1.Profile{Spec: profilev1.ProfileSpec{ServiceAccounts: test.serviceAccounts}}
+		err := validateServiceAccounts(profile, DefaultRoleCatalog())
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("Expect:\n%v; Output:\n%v", test.err, err)
+		}
+	}
+}
+
+func TestPluginServiceAccounts(t *testing.T) {
+	if sas := pluginServiceAccounts(nil, DEFAULT_EDITOR, DEFAULT_VIEWER); len(sas) != 2 || sas[0] != DEFAULT_EDITOR {
+		t.Errorf("Expect:\n%v; Output:\n%v", []string{DEFAULT_EDITOR, DEFAULT_VIEWER}, sas)
+	}
+	if sas := pluginServiceAccounts([]string{"pipeline-runner"}, DEFAULT_EDITOR); len(sas) != 1 || sas[0] != "pipeline-runner" {
+		t.Errorf("Expect:\n%v; Output:\n%v", []string{"pipeline-runner"}, sas)
+	}
+	if err := validatePluginServiceAccounts([]string{"pipeline-runner", "../x"}); err == nil {
+		t.Errorf("Expect:\ninvalid service account; Output:\n%v", err)
+	}
+}
+
+func TestUpdateServiceAccounts(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := profilev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", UID: "profile-uid"},
+		Spec: profilev1.ProfileSpec{
+			ServiceAccounts: []profilev1.ProfileServiceAccount{
+				{Name: "pipeline-runner", ClusterRole: "kubeflow-edit"},
+				{Name: "reporter", ClusterRole: "kubeflow-view"},
+			},
+		},
+	}
+	unmanaged := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "alice"}}
+	r := &ProfileReconciler{Client: fake.NewFakeClientWithScheme(scheme, unmanaged), Scheme: scheme, Log: ctrl.Log}
+	ctx := context.Background()
+
+	if err := r.updateServiceAccounts(profile); err != nil {
+		t.Fatal(err)
+	}
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "serviceaccount-pipeline-runner", Namespace: "alice"}, roleBinding); err != nil {
+		t.Fatal(err)
+	}
+	if roleBinding.RoleRef.Name != "kubeflow-edit" || roleBinding.Subjects[0].Name != "pipeline-runner" {
+		t.Errorf("Expect:\nkubeflow-edit pipeline-runner; Output:\n%v %v", roleBinding.RoleRef.Name, roleBinding.Subjects[0].Name)
+	}
+
+	// Removed service accounts are deleted with their RoleBinding
+	profile.Spec.ServiceAccounts = profile.Spec.ServiceAccounts[:1]
+	if err := r.updateServiceAccounts(profile); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "reporter", Namespace: "alice"}, &corev1.ServiceAccount{}); !errors.IsNotFound(err) {
+		t.Errorf("Expect:\nservice account reporter deleted; Output:\n%v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "serviceaccount-reporter", Namespace: "alice"}, &rbacv1.RoleBinding{}); !errors.IsNotFound(err) {
+		t.Errorf("Expect:\nRoleBinding serviceaccount-reporter deleted; Output:\n%v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pipeline-runner", Namespace: "alice"}, &corev1.ServiceAccount{}); err != nil {
+		t.Errorf("Expect:\nservice account pipeline-runner kept; Output:\n%v", err)
+	}
+
+	// Service accounts which aren't managed by the profile aren't taken over
+	profile.Spec.ServiceAccounts = []profilev1.ProfileServiceAccount{{Name: "existing", ClusterRole: "kubeflow-edit"}}
+	if err := r.updateServiceAccounts(profile); err == nil || !strings.Contains(err.Error(), "isn't managed by the profile") {
+		t.Errorf("Expect:\nisn't managed by the profile; Output:\n%v", err)
+	}
+}
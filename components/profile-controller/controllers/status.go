@@ -0,0 +1,226 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// The conditions Ready is computed from, in the order they are reconciled.
+var subConditions = []string{
+	profilev1.NamespaceReady,
+	profilev1.RBACReady,
+	profilev1.QuotaReady,
+	profilev1.PluginsReady,
+	profilev1.TemplateReady,
+}
+
+// profileStatus collects the conditions and plugin statuses computed during
+// a reconciliation. They are kept apart from the Profile, which is updated
+// during the reconciliation, and written with writeStatus.
+type profileStatus struct {
+	conditions []profilev1.ProfileCondition
+	plugins    []profilev1.PluginStatus
+	// Whether quota was set during the reconciliation, it's nil when the
+	// namespace has no quota.
+	quotaSet bool
+	quota    *corev1.ResourceQuotaStatus
+	// The plugins applied to the namespace, nil when they weren't changed
+	// during the reconciliation.
+	appliedPlugins []profilev1.Plugin
+}
+
+func (s *profileStatus) setCondition(condType string, ok bool, reason, message string) {
+	status := corev1.ConditionTrue
+	if !ok {
+		status = corev1.ConditionFalse
+	}
+	s.conditions = append(s.conditions, profilev1.ProfileCondition{
+		Type:    condType,
+		Status:  string(status),
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+func (s *profileStatus) setPlugin(kind string, err error) {
+	plugin := profilev1.PluginStatus{
+		Kind:   kind,
+		Status: string(corev1.ConditionTrue),
+	}
+	if err != nil {
+		plugin.Status = string(corev1.ConditionFalse)
+		plugin.Message = err.Error()
+	}
+	s.plugins = append(s.plugins, plugin)
+}
+
+func (s *profileStatus) setAppliedPlugins(plugins []profilev1.Plugin) {
+	s.appliedPlugins = plugins
+}
+
+func (s *profileStatus) setQuota(quota *corev1.ResourceQuotaStatus) {
+	s.quotaSet = true
+	s.quota = quota.DeepCopy()
+}
+
+// setCondition sets the condition in the status, replacing the one of the
+// same type. Its lastTransitionTime is only changed when its status changes.
+func setCondition(status *profilev1.ProfileStatus, generation int64, condition profilev1.ProfileCondition) {
+	condition.ObservedGeneration = generation
+	condition.LastTransitionTime = metav1.Now()
+	for i := range status.Conditions {
+		if status.Conditions[i].Type != condition.Type {
+			continue
+		}
+		if status.Conditions[i].Status == condition.Status {
+			condition.LastTransitionTime = status.Conditions[i].LastTransitionTime
+		}
+		status.Conditions[i] = condition
+		return
+	}
+	status.Conditions = append(status.Conditions, condition)
+}
+
+// getCondition returns the condition of the given type, or nil.
+func getCondition(status *profilev1.ProfileStatus, condType string) *profilev1.ProfileCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// setPluginStatuses replaces the plugin statuses, keeping the
+// lastTransitionTime of the plugins whose status didn't change.
+func setPluginStatuses(status *profilev1.ProfileStatus, plugins []profilev1.PluginStatus) {
+	old := map[string]profilev1.PluginStatus{}
+	for _, p := range status.Plugins {
+		old[p.Kind] = p
+	}
+	status.Plugins = nil
+	for _, p := range plugins {
+		p.LastTransitionTime = metav1.Now()
+		if o, ok := old[p.Kind]; ok && o.Status == p.Status {
+			p.LastTransitionTime = o.LastTransitionTime
+		}
+		status.Plugins = append(status.Plugins, p)
+	}
+}
+
+// computeStatus returns the status resulting from applying "s" to the status
+// of "instance". Ready is only True when all the other conditions are.
+func computeStatus(instance *profilev1.Profile, s *profileStatus) *profilev1.ProfileStatus {
+	status := instance.Status.DeepCopy()
+	// Conditions from older versions of the controller
+	conditions := status.Conditions[:0]
+	for _, c := range status.Conditions {
+		if c.Type != profilev1.ProfileFailed && c.Type != profilev1.ProfileSucceed {
+			conditions = append(conditions, c)
+		}
+	}
+	status.Conditions = conditions
+
+	for _, c := range s.conditions {
+		setCondition(status, instance.Generation, c)
+	}
+	if s.plugins != nil {
+		setPluginStatuses(status, s.plugins)
+	}
+	if s.quotaSet {
+		status.Quota = s.quota
+	}
+	if s.appliedPlugins != nil {
+		status.AppliedPlugins = nil
+		if len(s.appliedPlugins) > 0 {
+			status.AppliedPlugins = s.appliedPlugins
+		}
+	}
+
+	ready := profilev1.ProfileCondition{
+		Type:    profilev1.ProfileReady,
+		Status:  string(corev1.ConditionTrue),
+		Reason:  "Reconciled",
+		Message: "All the resources of the profile are ready",
+	}
+	// A failed condition is reported rather than the ones that weren't
+	// reconciled because of it.
+	missing := ""
+	for _, condType := range subConditions {
+		c := getCondition(status, condType)
+		if c == nil {
+			if missing == "" {
+				missing = condType
+			}
+			continue
+		}
+		if c.Status != string(corev1.ConditionTrue) {
+			ready.Status, ready.Reason, ready.Message = c.Status, c.Reason, c.Message
+			missing = ""
+			break
+		}
+	}
+	if missing != "" {
+		ready.Status, ready.Reason = string(corev1.ConditionUnknown), missing+"Unknown"
+		ready.Message = fmt.Sprintf("%v has not been reconciled yet", missing)
+	}
+	setCondition(status, instance.Generation, ready)
+	status.ObservedGeneration = instance.Generation
+	return status
+}
+
+// writeStatus writes the conditions and plugin statuses of "s" to the
+// status subresource of "instance", if they changed.
+func (r *ProfileReconciler) writeStatus(ctx context.Context, instance *profilev1.Profile, s *profileStatus) error {
+	status := computeStatus(instance, s)
+	SetQuotaMetrics(instance.Name, status.Quota)
+	SetProfileMetrics(instance, status)
+	// Semantic comparison, as quantities of the quota may differ in their format
+	if apiequality.Semantic.DeepEqual(status, &instance.Status) {
+		return nil
+	}
+	oldQuota := instance.Status.Quota
+	instance.Status = *status
+	if err := r.Status().Update(ctx, instance); err != nil {
+		return err
+	}
+	r.recordQuotaEvents(instance, oldQuota, status.Quota)
+	return nil
+}
+
+// failAndReturn sets the condition to False, writes the status and returns
+// "err", so that the request is requeued unless "err" is nil.
+func (r *ProfileReconciler) failAndReturn(ctx context.Context, instance *profilev1.Profile, s *profileStatus,
+	condType, reason, message string, err error) (ctrl.Result, error) {
+	s.setCondition(condType, false, reason, message)
+	if updateErr := r.writeStatus(ctx, instance, s); updateErr != nil {
+		r.Log.Error(updateErr, "error updating status", "profile", instance.Name)
+		if err == nil {
+			return reconcile.Result{}, updateErr
+		}
+	}
+	return reconcile.Result{}, err
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestComputeStatusReady(t *testing.T) {
+	allReady := &profileStatus{}
+	for _, condType := range subConditions {
+		allReady.setCondition(condType, true, condType, "")
+	}
+	quotaFailed := &profileStatus{}
+	quotaFailed.setCondition(profilev1.NamespaceReady, true, "NamespaceReady", "")
+	quotaFailed.setCondition(profilev1.RBACReady, true, "RBACReady", "")
+	quotaFailed.setCondition(profilev1.QuotaReady, false, "ResourceQuotaFailed", "forbidden")
+	nsOnly := &profileStatus{}
+	nsOnly.setCondition(profilev1.NamespaceReady, true, "NamespaceReady", "")
+
+	tests := []struct {
+		status         *profileStatus
+		expectedStatus string
+		expectedReason string
+	}{
+		{allReady, string(corev1.ConditionTrue), "Reconciled"},
+		{quotaFailed, string(corev1.ConditionFalse), "ResourceQuotaFailed"},
+		{nsOnly, string(corev1.ConditionUnknown), profilev1.RBACReady + "Unknown"},
+	}
+	for _, test := range tests {
+		instance := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "alice", Generation: 3}}
+		status := computeStatus(instance, test.status)
+		ready := getCondition(status, profilev1.ProfileReady)
+		if ready == nil {
+			t.Fatalf("Expect:\nReady condition; Output:\n%v", status.Conditions)
+		}
+		output := fmt.Sprintf("%v/%v", ready.Status, ready.Reason)
+		expected := fmt.Sprintf("%v/%v", test.expectedStatus, test.expectedReason)
+		if output != expected {
+			t.Errorf("Expect:\n%v; Output:\n%v", expected, output)
+		}
+		if status.ObservedGeneration != 3 || ready.ObservedGeneration != 3 {
+			t.Errorf("Expect:\nobservedGeneration 3; Output:\n%v", status)
+		}
+	}
+}
+
+func TestComputeStatusConditions(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	instance := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Generation: 2},
+		Status: profilev1.ProfileStatus{
+			Conditions: []profilev1.ProfileCondition{
+				{Type: profilev1.ProfileFailed, Message: "legacy failure"},
+				{Type: profilev1.NamespaceReady, Status: string(corev1.ConditionTrue), LastTransitionTime: past},
+				{Type: profilev1.RBACReady, Status: string(corev1.ConditionTrue), LastTransitionTime: past},
+			},
+		},
+	}
+	s := &profileStatus{}
+	s.setCondition(profilev1.NamespaceReady, true, "NamespaceReady", "")
+	s.setCondition(profilev1.RBACReady, false, "RoleBindingFailed", "forbidden")
+	s.setPlugin(KIND_WORKLOAD_IDENTITY, nil)
+	s.setPlugin(KIND_AWS_IAM_FOR_SERVICE_ACCOUNT, fmt.Errorf("denied"))
+	status := computeStatus(instance, s)
+
+	seen := map[string]bool{}
+	for _, c := range status.Conditions {
+		if seen[c.Type] {
+			t.Errorf("Expect:\nunique conditions; Output:\n%v", status.Conditions)
+		}
+		seen[c.Type] = true
+	}
+	if seen[profilev1.ProfileFailed] {
+		t.Errorf("Expect:\nno %v condition; Output:\n%v", profilev1.ProfileFailed, status.Conditions)
+	}
+	if ns := getCondition(status, profilev1.NamespaceReady); !ns.LastTransitionTime.Equal(&past) {
+		t.Errorf("Expect:\n%v; Output:\n%v", past, ns.LastTransitionTime)
+	}
+	if rbac := getCondition(status, profilev1.RBACReady); rbac.LastTransitionTime.Equal(&past) {
+		t.Errorf("Expect:\nnew lastTransitionTime; Output:\n%v", rbac.LastTransitionTime)
+	}
+	if len(status.Plugins) != 2 || status.Plugins[0].Status != string(corev1.ConditionTrue) ||
+		status.Plugins[1].Status != string(corev1.ConditionFalse) || status.Plugins[1].Message != "denied" {
+		t.Errorf("Expect:\n2 plugin statuses; Output:\n%v", status.Plugins)
+	}
+	if len(instance.Status.Conditions) != 3 {
+		t.Errorf("Expect:\ninstance status unchanged; Output:\n%v", instance.Status.Conditions)
+	}
+}
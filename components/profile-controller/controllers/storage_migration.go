@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Name of the CustomResourceDefinition of the Profiles
+const PROFILESCRD = "profiles.kubeflow.org"
+
+// Version the Profiles are stored in
+const PROFILESTORAGEVERSION = "v1"
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=update
+
+// MigrateStorageVersion rewrites all the Profiles so that they are stored as
+// v1, then drops the other versions from the stored versions of the Profile
+// CRD, after which they can be removed from the CRD.
+func MigrateStorageVersion(ctx context.Context, c client.Client, log logr.Logger) error {
+	profiles := &profilev1.ProfileList{}
+	if err := c.List(ctx, profiles); err != nil {
+		return err
+	}
+	for i := range profiles.Items {
+		profile := &profiles.Items[i]
+		// The API server writes the unchanged object in the storage version
+		if err := c.Update(ctx, profile); err != nil {
+			// A deleted profile doesn't need to be migrated, and a profile updated
+			// since it was listed was already written in the storage version
+			if errors.IsNotFound(err) || errors.IsConflict(err) {
+				continue
+			}
+			return fmt.Errorf("error migrating profile %v: %v", profile.Name, err)
+		}
+		log.Info("Migrated profile", "profile", profile.Name)
+	}
+
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(schema.GroupVersionKind{
+		Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+	if err := c.Get(ctx, types.NamespacedName{Name: PROFILESCRD}, crd); err != nil {
+		return err
+	}
+	storedVersions, _, err := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+	if err != nil {
+		return err
+	}
+	if len(storedVersions) == 1 && storedVersions[0] == PROFILESTORAGEVERSION {
+		return nil
+	}
+	if err := unstructured.SetNestedStringSlice(
+		crd.Object, []string{PROFILESTORAGEVERSION}, "status", "storedVersions"); err != nil {
+		return err
+	}
+	if err := c.Status().Update(ctx, crd); err != nil {
+		return err
+	}
+	log.Info("Updated the stored versions of the CRD", "crd", PROFILESCRD, "previous", storedVersions)
+	return nil
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMigrateStorageVersion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := profilev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": PROFILESCRD},
+		"status":     map[string]interface{}{"storedVersions": []interface{}{"v1beta1", "v1"}},
+	}}
+	c := fake.NewFakeClientWithScheme(scheme,
+		&profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "alice"}},
+		&profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "bob"}},
+		crd)
+	if err := MigrateStorageVersion(context.Background(), c, ctrl.Log); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated := &unstructured.Unstructured{}
+	migrated.SetGroupVersionKind(crd.GroupVersionKind())
+	if err := c.Get(context.Background(), types.NamespacedName{Name: PROFILESCRD}, migrated); err != nil {
+		t.Fatal(err)
+	}
+	storedVersions, _, _ := unstructured.NestedStringSlice(migrated.Object, "status", "storedVersions")
+	if !reflect.DeepEqual(storedVersions, []string{PROFILESTORAGEVERSION}) {
+		t.Errorf("Expect:\n%v; Output:\n%v", []string{PROFILESTORAGEVERSION}, storedVersions)
+	}
+	// Migrating again doesn't change anything
+	if err := MigrateStorageVersion(context.Background(), c, ctrl.Log); err != nil {
+		t.Errorf("Expect:\nnil; Output:\n%v", err)
+	}
+}
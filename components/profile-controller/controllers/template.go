@@ -0,0 +1,310 @@
+/*
+Copyright 2021 The Kubeflow Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Label of the PodDefaults created from the ProfileTemplate, used to prune
+// the ones removed from it.
+const TEMPLATELABEL = "profiles.kubeflow.org/template"
+
+// PodDefaults are defined by the admission-webhook, so they are handled as
+// unstructured objects.
+var podDefaultGVK = schema.GroupVersionKind{Group: "kubeflow.org", Version: "v1alpha1", Kind: "PodDefault"}
+
+// getTemplate returns the ProfileTemplate referenced by "profileIns", or nil
+// if it doesn't reference one.
+func (r *ProfileReconciler) getTemplate(ctx context.Context,
+	profileIns *profilev1.Profile) (*profilev1.ProfileTemplate, error) {
+	if profileIns.Spec.TemplateRef == nil {
+		return nil, nil
+	}
+	template := &profilev1.ProfileTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profileIns.Spec.TemplateRef.Name}, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// mergeTemplate returns a copy of "profileIns" with the defaults of
+// "template" applied. The hard limits and scopes of the profile's
+// ResourceQuotaSpec override the template's, and so do its LimitRangeSpec
+// and its plugins of the same kind.
+func mergeTemplate(profileIns *profilev1.Profile, template *profilev1.ProfileTemplate) *profilev1.Profile {
+	profile := profileIns.DeepCopy()
+	if template == nil {
+		return profile
+	}
+
+	quota := template.Spec.ResourceQuotaSpec.DeepCopy()
+	for name, quantity := range profile.Spec.ResourceQuotaSpec.Hard {
+		if quota.Hard == nil {
+			quota.Hard = corev1.ResourceList{}
+		}
+		quota.Hard[name] = quantity
+	}
+	if len(profile.Spec.ResourceQuotaSpec.Scopes) > 0 {
+		quota.Scopes = profile.Spec.ResourceQuotaSpec.Scopes
+	}
+	if profile.Spec.ResourceQuotaSpec.ScopeSelector != nil {
+		quota.ScopeSelector = profile.Spec.ResourceQuotaSpec.ScopeSelector
+	}
+	profile.Spec.ResourceQuotaSpec = *quota
+
+	if profile.Spec.LimitRangeSpec == nil && template.Spec.LimitRangeSpec != nil {
+		profile.Spec.LimitRangeSpec = template.Spec.LimitRangeSpec.DeepCopy()
+	}
+
+	kinds := map[string]bool{}
+	for _, p := range profile.Spec.Plugins {
+		kinds[p.Kind] = true
+	}
+	for _, p := range template.Spec.Plugins {
+		if !kinds[p.Kind] {
+			profile.Spec.Plugins = append(profile.Spec.Plugins, *p.DeepCopy())
+		}
+	}
+	return profile
+}
+
+// Annotation of the namespaces listing the keys of the labels and
+// annotations set from their ProfileTemplate, used to remove the ones removed
+// from it.
+const TEMPLATEMETADATAANNOTATION = "profiles.kubeflow.org/template-metadata"
+
+// reservedNamespaceAnnotations are the annotations of the namespaces the
+// controller manages itself, which templates can't set.
+var reservedNamespaceAnnotations = map[string]bool{
+	"owner":                    true,
+	OWNERKINDANNOTATION:        true,
+	PRIORSTATEANNOTATION:       true,
+	ADOPTIONAPPROVEDANNOTATION: true,
+	TEMPLATEMETADATAANNOTATION: true,
+}
+
+// templateMetadata is the value of the TEMPLATEMETADATAANNOTATION annotation.
+type templateMetadata struct {
+	Labels      []string `json:"labels,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// validateTemplate checks that "template" doesn't set the namespace labels
+// and annotations managed by the controller, like the labels of the
+// authorization backend "backendLabels", which would be changed back and
+// forth on every reconciliation.
+func validateTemplate(template *profilev1.ProfileTemplate, backendLabels map[string]string) error {
+	for k := range template.Spec.NamespaceLabels {
+		if _, ok := backendLabels[k]; ok {
+			return fmt.Errorf("namespace label %v of template %v is managed by the profile controller",
+				k, template.Name)
+		}
+	}
+	for k := range template.Spec.NamespaceAnnotations {
+		if reservedNamespaceAnnotations[k] {
+			return fmt.Errorf("namespace annotation %v of template %v is managed by the profile controller",
+				k, template.Name)
+		}
+	}
+	return nil
+}
+
+// updateNamespaceMetadata sets the labels and annotations of "template" on
+// the namespace, removes the ones it set before which were removed from the
+// template, and returns whether it changed. The reserved annotations, like
+// the owner one used to check the ownership of the namespace, are never
+// changed.
+func updateNamespaceMetadata(ns *corev1.Namespace, template *profilev1.ProfileTemplate) bool {
+	labels, annotations := map[string]string{}, map[string]string{}
+	if template != nil {
+		labels = template.Spec.NamespaceLabels
+		for k, v := range template.Spec.NamespaceAnnotations {
+			if !reservedNamespaceAnnotations[k] {
+				annotations[k] = v
+			}
+		}
+	}
+	if ns.Labels == nil {
+		ns.Labels = make(map[string]string)
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string)
+	}
+	updated := false
+	applied := templateMetadata{}
+	if data, ok := ns.Annotations[TEMPLATEMETADATAANNOTATION]; ok {
+		// An invalid annotation only keeps the previous keys from being removed
+		_ = json.Unmarshal([]byte(data), &applied)
+	}
+	for _, k := range applied.Labels {
+		if _, ok := labels[k]; !ok {
+			if _, found := ns.Labels[k]; found {
+				delete(ns.Labels, k)
+				updated = true
+			}
+		}
+	}
+	for _, k := range applied.Annotations {
+		if _, ok := annotations[k]; !ok && !reservedNamespaceAnnotations[k] {
+			if _, found := ns.Annotations[k]; found {
+				delete(ns.Annotations, k)
+				updated = true
+			}
+		}
+	}
+
+	current := templateMetadata{}
+	for k, v := range labels {
+		current.Labels = append(current.Labels, k)
+		if ns.Labels[k] != v {
+			ns.Labels[k] = v
+			updated = true
+		}
+	}
+	for k, v := range annotations {
+		current.Annotations = append(current.Annotations, k)
+		if ns.Annotations[k] != v {
+			ns.Annotations[k] = v
+			updated = true
+		}
+	}
+	sort.Strings(current.Labels)
+	sort.Strings(current.Annotations)
+	if len(current.Labels) == 0 && len(current.Annotations) == 0 {
+		if _, ok := ns.Annotations[TEMPLATEMETADATAANNOTATION]; ok {
+			delete(ns.Annotations, TEMPLATEMETADATAANNOTATION)
+			updated = true
+		}
+	} else if data, err := json.Marshal(current); err == nil &&
+		ns.Annotations[TEMPLATEMETADATAANNOTATION] != string(data) {
+		ns.Annotations[TEMPLATEMETADATAANNOTATION] = string(data)
+		updated = true
+	}
+	return updated
+}
+
+// updatePodDefaults create or update the PodDefaults of the template in the
+// namespace of "profileIns", and delete the ones removed from it.
+func (r *ProfileReconciler) updatePodDefaults(profileIns *profilev1.Profile,
+	podDefaults []profilev1.PodDefaultTemplate) error {
+	ctx := context.Background()
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	desired := map[string]bool{}
+	for _, podDefault := range podDefaults {
+		spec := map[string]interface{}{}
+		if podDefault.Spec != nil {
+			// Decoded like unstructured objects, so that the specs can be compared
+			if err := utiljson.Unmarshal(podDefault.Spec.Raw, &spec); err != nil {
+				return err
+			}
+		}
+		desired[podDefault.Name] = true
+
+		found := &unstructured.Unstructured{}
+		found.SetGroupVersionKind(podDefaultGVK)
+		err := r.Get(ctx, types.NamespacedName{Name: podDefault.Name, Namespace: profileIns.Name}, found)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if errors.IsNotFound(err) {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(podDefaultGVK)
+			obj.SetName(podDefault.Name)
+			obj.SetNamespace(profileIns.Name)
+			obj.SetLabels(map[string]string{TEMPLATELABEL: "true"})
+			obj.Object["spec"] = spec
+			if err := controllerutil.SetControllerReference(profileIns, obj, r.Scheme); err != nil {
+				return err
+			}
+			logger.Info("Creating PodDefault", "namespace", obj.GetNamespace(), "name", obj.GetName())
+			if err := r.Create(ctx, obj); err != nil {
+				return err
+			}
+			continue
+		}
+		if !metav1.IsControlledBy(found, profileIns) {
+			logger.Info("Not updating PodDefault, it isn't owned by the profile", "name", found.GetName())
+			continue
+		}
+		if !reflect.DeepEqual(found.Object["spec"], spec) {
+			r.recordDrift(profileIns, podDefaultGVK.Kind, found, "spec")
+			found.Object["spec"] = spec
+			logger.Info("Updating PodDefault", "namespace", found.GetNamespace(), "name", found.GetName())
+			if err := r.Update(ctx, found); err != nil {
+				return err
+			}
+		}
+	}
+
+	found := &unstructured.UnstructuredList{}
+	found.SetGroupVersionKind(podDefaultGVK.GroupVersion().WithKind(podDefaultGVK.Kind + "List"))
+	if err := r.List(ctx, found, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{TEMPLATELABEL: "true"}); err != nil {
+		// PodDefaults are optional, there is nothing to prune if they aren't installed
+		if meta.IsNoMatchError(err) && len(podDefaults) == 0 {
+			return nil
+		}
+		return err
+	}
+	for i := range found.Items {
+		podDefault := &found.Items[i]
+		if desired[podDefault.GetName()] || !metav1.IsControlledBy(podDefault, profileIns) {
+			continue
+		}
+		logger.Info("Deleting PodDefault", "namespace", podDefault.GetNamespace(), "name", podDefault.GetName())
+		if err := r.Delete(ctx, podDefault); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// profilesForTemplate returns the requests of the Profiles referencing the
+// ProfileTemplate, so that they are reconciled when it changes.
+func (r *ProfileReconciler) profilesForTemplate(a handler.MapObject) []reconcile.Request {
+	profiles := &profilev1.ProfileList{}
+	if err := r.List(context.Background(), profiles); err != nil {
+		r.Log.Error(err, "error listing profiles", "template", a.Meta.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, profile := range profiles.Items {
+		if profile.Spec.TemplateRef != nil && profile.Spec.TemplateRef.Name == a.Meta.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: profile.Name}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeTemplate(t *testing.T) {
+	template := &profilev1.ProfileTemplate{
+		Spec: profilev1.ProfileTemplateSpec{
+			ResourceQuotaSpec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("8"),
+					corev1.ResourceMemory: resource.MustParse("16Gi"),
+				},
+			},
+			Plugins: []profilev1.Plugin{
+				{TypeMeta: metav1.TypeMeta{Kind: KIND_WORKLOAD_IDENTITY}},
+				{TypeMeta: metav1.TypeMeta{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT}},
+			},
+			LimitRangeSpec: &corev1.LimitRangeSpec{
+				Limits: []corev1.LimitRangeItem{{Type: corev1.LimitTypeContainer}},
+			},
+		},
+	}
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec: profilev1.ProfileSpec{
+			ResourceQuotaSpec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("16"),
+				},
+			},
+			Plugins: []profilev1.Plugin{
+				{TypeMeta: metav1.TypeMeta{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT, APIVersion: "override"}},
+			},
+		},
+	}
+
+	merged := mergeTemplate(profile, template)
+	expectedHard := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("16"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+	}
+	if !reflect.DeepEqual(merged.Spec.ResourceQuotaSpec.Hard, expectedHard) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedHard, merged.Spec.ResourceQuotaSpec.Hard)
+	}
+	expectedPlugins := []profilev1.Plugin{
+		{TypeMeta: metav1.TypeMeta{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT, APIVersion: "override"}},
+		{TypeMeta: metav1.TypeMeta{Kind: KIND_WORKLOAD_IDENTITY}},
+	}
+	if !reflect.DeepEqual(merged.Spec.Plugins, expectedPlugins) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedPlugins, merged.Spec.Plugins)
+	}
+	if !reflect.DeepEqual(merged.Spec.LimitRangeSpec, template.Spec.LimitRangeSpec) {
+		t.Errorf("Expect:\n%v; Output:\n%v", template.Spec.LimitRangeSpec, merged.Spec.LimitRangeSpec)
+	}
+	if len(profile.Spec.Plugins) != 1 || len(profile.Spec.ResourceQuotaSpec.Hard) != 1 {
+		t.Errorf("Expect:\nprofile unchanged; Output:\n%v", profile.Spec)
+	}
+	if len(template.Spec.ResourceQuotaSpec.Hard) != 2 {
+		t.Errorf("Expect:\ntemplate unchanged; Output:\n%v", template.Spec)
+	}
+	if noTemplate := mergeTemplate(profile, nil); !reflect.DeepEqual(noTemplate, profile) {
+		t.Errorf("Expect:\n%v; Output:\n%v", profile, noTemplate)
+	}
+}
+
+func TestUpdateNamespaceMetadata(t *testing.T) {
+	template := &profilev1.ProfileTemplate{
+		Spec: profilev1.ProfileTemplateSpec{
+			NamespaceLabels:      map[string]string{"team": "ml", "env": "prod"},
+			NamespaceAnnotations: map[string]string{"cost-center": "42", "owner": "mallory"},
+		},
+	}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "alice",
+			Labels:      map[string]string{"env": "dev", "user": "alice"},
+			Annotations: map[string]string{"owner": "alice"},
+		},
+	}
+	if !updateNamespaceMetadata(ns, template) {
+		t.Errorf("Expect:\nnamespace updated; Output:\nnot updated")
+	}
+	expectedLabels := map[string]string{"team": "ml", "env": "prod", "user": "alice"}
+	if !reflect.DeepEqual(ns.Labels, expectedLabels) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedLabels, ns.Labels)
+	}
+	expectedAnnotations := map[string]string{
+		"cost-center":              "42",
+		"owner":                    "alice",
+		TEMPLATEMETADATAANNOTATION: `{"labels":["env","team"],"annotations":["cost-center"]}`,
+	}
+	if !reflect.DeepEqual(ns.Annotations, expectedAnnotations) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedAnnotations, ns.Annotations)
+	}
+	if updateNamespaceMetadata(ns, template) {
+		t.Errorf("Expect:\nnamespace unchanged; Output:\nupdated")
+	}
+
+	// The keys removed from the template are removed from the namespace
+	template.Spec.NamespaceLabels = map[string]string{"team": "ml"}
+	template.Spec.NamespaceAnnotations = nil
+	if !updateNamespaceMetadata(ns, template) {
+		t.Errorf("Expect:\nnamespace updated; Output:\nnot updated")
+	}
+	expectedLabels = map[string]string{"team": "ml", "user": "alice"}
+	if !reflect.DeepEqual(ns.Labels, expectedLabels) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedLabels, ns.Labels)
+	}
+	expectedAnnotations = map[string]string{"owner": "alice", TEMPLATEMETADATAANNOTATION: `{"labels":["team"]}`}
+	if !reflect.DeepEqual(ns.Annotations, expectedAnnotations) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedAnnotations, ns.Annotations)
+	}
+
+	// So are all of them when the profile stops using the template
+	if !updateNamespaceMetadata(ns, nil) {
+		t.Errorf("Expect:\nnamespace updated; Output:\nnot updated")
+	}
+	expectedLabels = map[string]string{"user": "alice"}
+	if !reflect.DeepEqual(ns.Labels, expectedLabels) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedLabels, ns.Labels)
+	}
+	expectedAnnotations = map[string]string{"owner": "alice"}
+	if !reflect.DeepEqual(ns.Annotations, expectedAnnotations) {
+		t.Errorf("Expect:\n%v; Output:\n%v", expectedAnnotations, ns.Annotations)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	backendLabels := (&IstioAuthorization{}).NamespaceLabels()
+	tests := []struct {
+		spec     profilev1.ProfileTemplateSpec
+		hasError bool
+	}{
+		{
+			spec: profilev1.ProfileTemplateSpec{
+				NamespaceLabels:      map[string]string{"team": "ml", "katib-metricscollector-injection": "disabled"},
+				NamespaceAnnotations: map[string]string{"cost-center": "42"},
+			},
+			hasError: false,
+		},
+		{
+			spec:     profilev1.ProfileTemplateSpec{NamespaceLabels: map[string]string{istioInjectionLabel: "disabled"}},
+			hasError: true,
+		},
+		{
+			spec:     profilev1.ProfileTemplateSpec{NamespaceAnnotations: map[string]string{"owner": "mallory"}},
+			hasError: true,
+		},
+	}
+	for _, test := range tests {
+		err := validateTemplate(&profilev1.ProfileTemplate{Spec: test.spec}, backendLabels)
+		if (err != nil) != test.hasError {
+			t.Errorf("Expect error: %v; Output: %v", test.hasError, err)
+		}
+	}
+}
@@ -12,6 +12,7 @@ require (
 	github.com/google/go-cmp v0.5.2 // indirect
 	github.com/google/gofuzz v1.2.0 // indirect
 	github.com/kr/text v0.2.0 // indirect
+	github.com/kubeflow/kubeflow/components/common v0.0.0-20200908101143-7f5e242f4671
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/onsi/ginkgo v1.13.0
 	github.com/onsi/gomega v1.10.2
@@ -53,3 +54,7 @@ require (
 )
 
 replace git.apache.org/thrift.git => github.com/apache/thrift v0.0.0-20180902110319-2566ecd5d999
+
+// Build with the latest `common` module, which holds the role catalog shared
+// by the profile controller and kfam.
+replace github.com/kubeflow/kubeflow/components/common => ../common
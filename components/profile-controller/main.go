@@ -16,23 +16,39 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	profilev1beta1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1beta1"
 	"github.com/kubeflow/kubeflow/components/profile-controller/controllers"
 	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	// +kubebuilder:scaffold:imports
 )
 
 const USERIDHEADER = "userid-header"
 const USERIDPREFIX = "userid-prefix"
+const GROUPSHEADER = "groups-header"
 const WORKLOADIDENTITY = "workload-identity"
+const QUOTATHRESHOLDS = "quota-thresholds"
+const AUTHORIZATIONBACKEND = "authorization-backend"
+const NETWORKPOLICIES = "network-policies"
+const NETWORKPOLICYNAMESPACES = "network-policy-namespaces"
+const ENABLEWEBHOOKS = "enable-webhooks"
+const RESYNCPERIOD = "resync-period"
+const ROLECATALOG = "role-catalog"
+const ENABLECONVERSIONWEBHOOK = "enable-conversion-webhook"
+const MIGRATESTORAGEVERSION = "migrate-storage-version"
 
 var (
 	scheme   = runtime.NewScheme()
@@ -43,6 +59,7 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = profilev1.AddToScheme(scheme)
+	_ = profilev1beta1.AddToScheme(scheme)
 	_ = istioSecurityClient.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
@@ -52,7 +69,17 @@ func main() {
 	var enableLeaderElection bool
 	var userIdHeader string
 	var userIdPrefix string
+	var groupsHeader string
 	var workloadIdentity string
+	var quotaThresholds string
+	var authorizationBackend string
+	var networkPolicies bool
+	var networkPolicyNamespaces string
+	var enableWebhooks bool
+	var resyncPeriod time.Duration
+	var roleCatalog string
+	var enableConversionWebhook bool
+	var migrateStorageVersion bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
@@ -60,18 +87,60 @@ func main() {
 		"Determines the namespace in which the leader election configmap will be created.")
 	flag.StringVar(&userIdHeader, USERIDHEADER, "x-goog-authenticated-user-email", "Key of request header containing user id")
 	flag.StringVar(&userIdPrefix, USERIDPREFIX, "accounts.google.com:", "Request header user id common prefix")
+	flag.StringVar(&groupsHeader, GROUPSHEADER, "", "Key of request header containing the groups of the user. Group owners only get access through Istio if it is set")
 	flag.StringVar(&workloadIdentity, WORKLOADIDENTITY, "", "Default identity (GCP service account) for workload_identity plugin")
+	flag.StringVar(&quotaThresholds, QUOTATHRESHOLDS, "0.8,0.95", "Comma separated usage ratios of the quota of a resource which emit an Event on the Profile when crossed")
+
+	flag.StringVar(&authorizationBackend, AUTHORIZATIONBACKEND, controllers.AUTHZBACKENDISTIO, "Backend granting access to the services of the profile namespaces, istio or none (Kubernetes RBAC only)")
+	flag.BoolVar(&networkPolicies, NETWORKPOLICIES, false, "Create default NetworkPolicies in the namespaces of the profiles which don't set spec.networkPolicy.enabled")
+	flag.StringVar(&networkPolicyNamespaces, NETWORKPOLICYNAMESPACES, "istio-system,kubeflow", "Comma separated namespaces allowed to send traffic to the profile namespaces by the default NetworkPolicies")
+	flag.BoolVar(&enableWebhooks, ENABLEWEBHOOKS, false, "Serve the admission webhook validating the plugins of profiles and profile templates, on port 9443")
+	flag.DurationVar(&resyncPeriod, RESYNCPERIOD, 30*time.Minute, "Period at which all the profiles are reconciled, to revert the manual changes to their objects missed by the watches")
+	flag.StringVar(&roleCatalog, ROLECATALOG, "", "Path of the YAML file mapping the roles of owners, contributors and service accounts to ClusterRoles, kubeflow-admin, kubeflow-edit and kubeflow-view if empty")
+	flag.BoolVar(&enableConversionWebhook, ENABLECONVERSIONWEBHOOK, false, "Serve the webhook converting profiles between v1beta1 and v1, on port 9443")
+	flag.BoolVar(&migrateStorageVersion, MIGRATESTORAGEVERSION, false, "Rewrite all the profiles in the storage version, v1, remove v1beta1 from the stored versions of the CRD and exit")
 
 	flag.Parse()
 
 	ctrl.SetLogger(zap.Logger(true))
 
+	if migrateStorageVersion {
+		c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create client")
+			os.Exit(1)
+		}
+		if err := controllers.MigrateStorageVersion(context.Background(), c, ctrl.Log.WithName("migration")); err != nil {
+			setupLog.Error(err, "unable to migrate the storage version")
+			os.Exit(1)
+		}
+		return
+	}
+
+	thresholds, err := controllers.ParseQuotaThresholds(quotaThresholds)
+	if err != nil {
+		setupLog.Error(err, "invalid flag", "flag", QUOTATHRESHOLDS)
+		os.Exit(1)
+	}
+	authorization, err := controllers.NewAuthorizationBackend(authorizationBackend)
+	if err != nil {
+		setupLog.Error(err, "invalid flag", "flag", AUTHORIZATIONBACKEND)
+		os.Exit(1)
+	}
+	roles, err := controllers.LoadRoleCatalog(roleCatalog)
+	if err != nil {
+		setupLog.Error(err, "invalid flag", "flag", ROLECATALOG)
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                  scheme,
 		MetricsBindAddress:      metricsAddr,
 		LeaderElection:          enableLeaderElection,
 		LeaderElectionNamespace: leaderElectionNamespace,
 		LeaderElectionID:        "kubeflow-profile-controller",
+		Port:                    9443,
+		SyncPeriod:              &resyncPeriod,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -79,16 +148,34 @@ func main() {
 	}
 
 	if err = (&controllers.ProfileReconciler{
-		Client:           mgr.GetClient(),
-		Scheme:           mgr.GetScheme(),
-		Log:              ctrl.Log.WithName("controllers").WithName("Profile"),
-		UserIdHeader:     userIdHeader,
-		UserIdPrefix:     userIdPrefix,
-		WorkloadIdentity: workloadIdentity,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("Profile"),
+		UserIdHeader:            userIdHeader,
+		UserIdPrefix:            userIdPrefix,
+		GroupsHeader:            groupsHeader,
+		WorkloadIdentity:        workloadIdentity,
+		Authorization:           authorization,
+		Recorder:                mgr.GetEventRecorderFor("profile-controller"),
+		QuotaThresholds:         thresholds,
+		NetworkPolicies:         networkPolicies,
+		NetworkPolicyNamespaces: splitList(networkPolicyNamespaces),
+		Roles:                   roles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Profile")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		mgr.GetWebhookServer().Register(controllers.PROFILEVALIDATINGWEBHOOKPATH,
+			&webhook.Admission{Handler: &controllers.ProfileValidator{}})
+	}
+	if enableConversionWebhook {
+		// Serves /convert, v1 being the hub version
+		if err = ctrl.NewWebhookManagedBy(mgr).For(&profilev1.Profile{}).Complete(); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Profile")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -97,3 +184,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma separated flag value, ignoring empty items.
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
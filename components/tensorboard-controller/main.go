@@ -16,6 +16,7 @@ import (
 	"flag"
 	"os"
 
+	"github.com/kubeflow/kubeflow/components/common/namespaces"
 	tensorboardv1alpha1 "github.com/kubeflow/kubeflow/components/tensorboard-controller/api/v1alpha1"
 	"github.com/kubeflow/kubeflow/components/tensorboard-controller/controllers"
 	corev1 "k8s.io/api/core/v1"
@@ -42,19 +43,36 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var watchNamespaces, namespaceSelector string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&watchNamespaces, "namespaces", "",
+		"Comma separated list of namespaces to watch. If unset, and no namespace-selector is given, all namespaces are watched.")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "",
+		"Label selector of the namespaces to watch, in addition to the ones of --namespaces. It is evaluated once at startup.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.Logger(true))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	restConfig := ctrl.GetConfigOrDie()
+	options := ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
 		LeaderElection:     enableLeaderElection,
 		Port:               9443,
-	})
+	}
+	nsList, err := namespaces.Resolve(restConfig, watchNamespaces, namespaceSelector)
+	if err != nil {
+		setupLog.Error(err, "unable to resolve the namespaces to watch")
+		os.Exit(1)
+	}
+	if len(nsList) > 0 {
+		setupLog.Info("restricting the controller to namespaces", "namespaces", nsList)
+	}
+	namespaces.SetManagerOptions(&options, nsList)
+
+	mgr, err := ctrl.NewManager(restConfig, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
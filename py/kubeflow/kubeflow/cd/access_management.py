@@ -11,6 +11,6 @@ def create_workflow(name=None, namespace=None, bucket=None, **kwargs):
     builder = kaniko_builder.Builder(name=name, namespace=namespace, bucket=bucket, **kwargs)
 
     return builder.build(dockerfile="components/access-management/Dockerfile",
-                         context="components/access-management/",
+                         context="components/",
                          destination=config.ACCESS_MANAGEMENT_IMAGE,
                          mem_override="6Gi")
@@ -11,5 +11,5 @@ def create_workflow(name=None, namespace=None, bucket=None, **kwargs):
     builder = kaniko_builder.Builder(name=name, namespace=namespace, bucket=bucket, **kwargs)
 
     return builder.build(dockerfile="components/profile-controller/Dockerfile",
-                         context="components/profile-controller/",
+                         context="components/",
                          destination=config.PROFILE_CONTROLLER_IMAGE)